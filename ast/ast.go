@@ -5,6 +5,7 @@ package ast
 import (
 	"bytes"
 	"intInGo/token"
+	"math/big"
 	"strings"
 )
 
@@ -66,11 +67,21 @@ func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
+type NullLiteral struct {
+	Token token.Token
+}
+
+func (nl *NullLiteral) expressionNode()      {}
+func (nl *NullLiteral) TokenLiteral() string { return nl.Token.Literal }
+func (nl *NullLiteral) String() string       { return nl.Token.Literal }
+
 // node for let statement (let x = 5)
 type LetStatement struct {
-	Token token.Token // token.LET
-	Name  *Identifier // identifier of the binding (x)
-	Value Expression  // expression producing the value (5)
+	Token   token.Token     // token.LET
+	Name    *Identifier     // identifier of the binding (x)
+	Pattern Pattern         // destructuring pattern instead of a name (let [a, b] = xs)
+	Type    *TypeAnnotation // optional declared type (let x: int = 5)
+	Value   Expression      // expression producing the value (5)
 }
 
 func (ls *LetStatement) statementNode()       { /* TODO */ }
@@ -80,7 +91,14 @@ func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.String())
+	}
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.String())
+	}
 	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String())
@@ -134,9 +152,10 @@ func (ie *IfExpression) String() string {
 }
 
 type CallExpression struct {
-	Token     token.Token // (
+	Token     token.Token // ( or ?(
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Optional  bool // f?(), null without a call if f is null
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -149,6 +168,9 @@ func (ce *CallExpression) String() string {
 	}
 
 	out.WriteString(ce.Function.String())
+	if ce.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
@@ -191,7 +213,8 @@ func (es *ExpressionStatement) String() string {
 
 type IntegerLiteral struct {
 	Token token.Token
-	Value int64 // actual value (not string) of the integer literal
+	Value int64    // actual value (not string) of the integer literal
+	Big   *big.Int // set instead of Value when it doesn't fit in an int64
 }
 
 func (il *IntegerLiteral) expressionNode() { /* TODO */ }
@@ -202,6 +225,17 @@ func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+// decimal like 12.50d, worth Value * 10^-Scale
+type DecimalLiteral struct {
+	Token token.Token
+	Value *big.Int
+	Scale int // digits after the point
+}
+
+func (dl *DecimalLiteral) expressionNode()      {}
+func (dl *DecimalLiteral) TokenLiteral() string { return dl.Token.Literal }
+func (dl *DecimalLiteral) String() string       { return dl.Token.Literal }
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
@@ -218,22 +252,46 @@ func (sl *StringLiteral) String() string {
 type FunctionLiteral struct {
 	Token      token.Token // fn token
 	Parameters []*Identifier
+	Defaults   map[*Identifier]Expression      // values of optional parameters (b = 10)
+	Rest       *Identifier                     // collects remaining arguments (...rest)
+	Types      map[*Identifier]*TypeAnnotation // declared types of parameters (x: int)
+	Patterns   map[*Identifier]Pattern         // destructured parameters, keyed by a placeholder named after the pattern
+	ReturnType *TypeAnnotation                 // optional declared result type
 	Body       *BlockStatement
+	Generator  bool // the body yields, calling the function creates a generator
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
-func (fl *FunctionLiteral) TokenLiteral() string { return fl.TokenLiteral() }
+func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	params := []string{}
 	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+		param := p.String()
+		if typ, ok := fl.Types[p]; ok {
+			param += ": " + typ.String()
+		}
+		if def, ok := fl.Defaults[p]; ok {
+			param += " = " + def.String()
+		}
+		params = append(params, param)
+	}
+	if fl.Rest != nil {
+		rest := "..." + fl.Rest.String()
+		if typ, ok := fl.Types[fl.Rest]; ok {
+			rest += ": " + typ.String()
+		}
+		params = append(params, rest)
 	}
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(") ")
+	out.WriteString(")")
+	if fl.ReturnType != nil {
+		out.WriteString(": " + fl.ReturnType.String())
+	}
+	out.WriteString(" ")
 	out.WriteString(fl.Body.String())
 
 	return out.String()
@@ -263,6 +321,7 @@ func (al *ArrayLiteral) String() string {
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	Keys  []Expression // keys of Pairs in source order
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -270,8 +329,8 @@ func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -320,9 +379,10 @@ func (ie *InfixExpression) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token // [ token
-	Left  Expression
-	Index Expression
+	Token    token.Token // [ or ?[ token
+	Left     Expression
+	Index    Expression
+	Optional bool // h?["k"], null if h is null
 }
 
 func (ie *IndexExpression) expressionNode()      {}
@@ -332,9 +392,374 @@ func (ie *IndexExpression) String() string {
 
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	if ie.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("]")
 
 	return out.String()
 }
+
+type SliceExpression struct {
+	Token    token.Token // [ or ?[ token
+	Left     Expression
+	Start    Expression // nil when omitted (a[:end])
+	End      Expression // nil when omitted (a[start:])
+	Optional bool       // a?[1:], null if a is null
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	if se.Optional {
+		out.WriteString("?")
+	}
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// placeholder for an expression that failed to parse
+type BadExpression struct {
+	Token token.Token // token the error was found at
+}
+
+func (be *BadExpression) expressionNode()      {}
+func (be *BadExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BadExpression) String() string       { return "<bad expression>" }
+
+// placeholder for a statement that failed to parse
+type BadStatement struct {
+	Token token.Token // first token of the statement
+}
+
+func (bs *BadStatement) statementNode()       {}
+func (bs *BadStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BadStatement) String() string       { return "<bad statement>" }
+
+// struct declaration (struct Point { x, y }), binding a constructor
+type StructStatement struct {
+	Token  token.Token // token.STRUCT
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (ss *StructStatement) statementNode()       {}
+func (ss *StructStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *StructStatement) String() string {
+	fields := []string{}
+	for _, f := range ss.Fields {
+		fields = append(fields, f.String())
+	}
+	return "struct " + ss.Name.String() + " { " + strings.Join(fields, ", ") + " }"
+}
+
+// field access (p.x)
+type MemberExpression struct {
+	Token    token.Token // .
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) String() string {
+	return "(" + me.Object.String() + "." + me.Property.String() + ")"
+}
+
+// assignment to a field (p.x = 5), evaluating to the assigned value
+type AssignExpression struct {
+	Token  token.Token // =
+	Target Expression  // MemberExpression
+	Value  Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) String() string {
+	return "(" + ae.Target.String() + " = " + ae.Value.String() + ")"
+}
+
+// yield value, hands a value of a generator to whoever iterates it
+type YieldExpression struct {
+	Token token.Token // yield token
+	Value Expression
+}
+
+func (ye *YieldExpression) expressionNode()      {}
+func (ye *YieldExpression) TokenLiteral() string { return ye.Token.Literal }
+func (ye *YieldExpression) String() string {
+	return "(yield " + ye.Value.String() + ")"
+}
+
+// type annotation like int, array<int>, hash<string, int> or
+// fn(int, bool): string. what the names mean is up to the type checker
+type TypeAnnotation struct {
+	Token  token.Token       // name of the type, or the fn keyword
+	Name   string            // int, array, fn, ...
+	Params []*TypeAnnotation // element types, or parameter types of fn
+	Result *TypeAnnotation   // result type of fn
+}
+
+func (ta *TypeAnnotation) TokenLiteral() string { return ta.Token.Literal }
+func (ta *TypeAnnotation) String() string {
+	params := []string{}
+	for _, p := range ta.Params {
+		params = append(params, p.String())
+	}
+
+	if ta.Name == "fn" {
+		out := "fn(" + strings.Join(params, ", ") + ")"
+		if ta.Result != nil {
+			out += ": " + ta.Result.String()
+		}
+		return out
+	}
+	if len(params) > 0 {
+		return ta.Name + "<" + strings.Join(params, ", ") + ">"
+	}
+	return ta.Name
+}
+
+// match (value) { pattern => result, ... }, evaluating the result of the
+// first arm whose pattern matches and whose guard holds
+type MatchExpression struct {
+	Token   token.Token // token.MATCH
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) String() string {
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+	return "match (" + me.Subject.String() + ") { " + strings.Join(arms, ", ") + " }"
+}
+
+type MatchArm struct {
+	Token   token.Token // token.ARROW
+	Pattern Pattern
+	Guard   Expression // nil without an if guard
+	Body    Expression
+}
+
+func (ma *MatchArm) TokenLiteral() string { return ma.Token.Literal }
+func (ma *MatchArm) String() string {
+	out := ma.Pattern.String()
+	if ma.Guard != nil {
+		out += " if " + ma.Guard.String()
+	}
+	return out + " => " + ma.Body.String()
+}
+
+// select { v = recv(ch) => a, send(ch, x) => b, _ => c }, waits until
+// one of the channel operations can go ahead and evaluates its body
+type SelectExpression struct {
+	Token token.Token // token.SELECT
+	Cases []*SelectCase
+}
+
+func (se *SelectExpression) expressionNode()      {}
+func (se *SelectExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SelectExpression) String() string {
+	cases := []string{}
+	for _, c := range se.Cases {
+		cases = append(cases, c.String())
+	}
+	return "select { " + strings.Join(cases, ", ") + " }"
+}
+
+// channel operation of a select and what to do when it goes ahead. the
+// default case _ has no channel, it's taken when no operation is ready
+type SelectCase struct {
+	Token   token.Token // token.ARROW
+	Op      token.Token // recv, send or _
+	Name    *Identifier // bound to the received value, nil if not
+	Channel Expression
+	Value   Expression // value to send, nil to receive
+	Body    Expression
+}
+
+func (sc *SelectCase) TokenLiteral() string { return sc.Token.Literal }
+func (sc *SelectCase) String() string {
+	var out string
+	switch {
+	case sc.Channel == nil:
+		out = "_"
+	case sc.Value != nil:
+		out = "send(" + sc.Channel.String() + ", " + sc.Value.String() + ")"
+	default:
+		out = "recv(" + sc.Channel.String() + ")"
+		if sc.Name != nil {
+			out = sc.Name.Value + " = " + out
+		}
+	}
+	return out + " => " + sc.Body.String()
+}
+
+// shape a value is matched against, binding names to its parts
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// integer, string or boolean the value has to equal
+type LiteralPattern struct {
+	Value Expression
+}
+
+func (lp *LiteralPattern) patternNode()         {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Value.TokenLiteral() }
+func (lp *LiteralPattern) String() string {
+	switch v := lp.Value.(type) {
+	case *StringLiteral:
+		return `"` + v.Value + `"`
+	case *PrefixExpression:
+		return v.Operator + v.Right.String()
+	}
+	return lp.Value.String()
+}
+
+// _, matching anything without binding it
+type WildcardPattern struct {
+	Token token.Token // token.IDENT
+}
+
+func (wp *WildcardPattern) patternNode()         {}
+func (wp *WildcardPattern) TokenLiteral() string { return wp.Token.Literal }
+func (wp *WildcardPattern) String() string       { return "_" }
+
+// a name, matching anything and binding it
+type BindingPattern struct {
+	Name *Identifier
+}
+
+func (bp *BindingPattern) patternNode()         {}
+func (bp *BindingPattern) TokenLiteral() string { return bp.Name.TokenLiteral() }
+func (bp *BindingPattern) String() string       { return bp.Name.String() }
+
+// [a, b, ...rest], matching arrays of exactly as many elements or, with a
+// rest, at least as many
+type ArrayPattern struct {
+	Token    token.Token // [
+	Elements []Pattern
+	Rest     *Identifier // nil without a rest
+}
+
+func (ap *ArrayPattern) patternNode()         {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// {"key": pattern, name, ...}, matching hashes having all the keys or
+// structs having all the fields. a name alone stands for "name": name
+type HashPattern struct {
+	Token  token.Token // {
+	Keys   []Expression
+	Values []Pattern
+}
+
+func (hp *HashPattern) patternNode()         {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) String() string {
+	pairs := []string{}
+	for i, key := range hp.Keys {
+		if hp.shorthand(i) {
+			pairs = append(pairs, hp.Values[i].String())
+			continue
+		}
+		pairs = append(pairs, (&LiteralPattern{Value: key}).String()+": "+hp.Values[i].String())
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// whether the i-th pair binds a string key to a name of the same text
+func (hp *HashPattern) shorthand(i int) bool {
+	key, ok := hp.Keys[i].(*StringLiteral)
+	binding, ok2 := hp.Values[i].(*BindingPattern)
+	return ok && ok2 && key.Value == binding.Name.Value
+}
+
+// names a pattern binds, in source order
+func PatternNames(p Pattern) []*Identifier {
+	var names []*Identifier
+	Inspect(p, func(n Node) bool {
+		switch n := n.(type) {
+		case *BindingPattern:
+			names = append(names, n.Name)
+		case *ArrayPattern:
+			for _, el := range n.Elements {
+				names = append(names, PatternNames(el)...)
+			}
+			if n.Rest != nil && n.Rest.Value != "_" {
+				names = append(names, n.Rest)
+			}
+			return false
+		case *Identifier, *LiteralPattern:
+			return false
+		}
+		return true
+	})
+	return names
+}
+
+// for (x in collection) { ... } or for (k, v in collection) { ... }. the
+// body shares the environment the loop is in, like if blocks do
+type ForStatement struct {
+	Token    token.Token // token.FOR
+	Key      *Identifier // the key or index with two names, else nil
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) String() string {
+	names := fs.Value.String()
+	if fs.Key != nil {
+		names = fs.Key.String() + ", " + names
+	}
+	return "for (" + names + " in " + fs.Iterable.String() + ") " + fs.Body.String()
+}
+
+type BreakStatement struct {
+	Token token.Token // token.BREAK
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) String() string       { return "break;" }
+
+type ContinueStatement struct {
+	Token token.Token // token.CONTINUE
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) String() string       { return "continue;" }
@@ -4,6 +4,7 @@ package ast
 
 import (
 	"intInGo/token"
+	"strings"
 	"testing"
 )
 
@@ -28,3 +29,55 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong, got=%q", program.String())
 	}
 }
+
+func TestInspect(t *testing.T) {
+	ident := func(name string) *Identifier {
+		return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+	}
+
+	// let f = fn(x) { x + 1 }; f(2)
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: ident("f"),
+				Value: &FunctionLiteral{
+					Parameters: []*Identifier{ident("x")},
+					Body: &BlockStatement{
+						Statements: []Statement{
+							&ExpressionStatement{
+								Expression: &InfixExpression{Left: ident("x"), Operator: "+", Right: &IntegerLiteral{Value: 1}},
+							},
+						},
+					},
+				},
+			},
+			&ExpressionStatement{
+				Expression: &CallExpression{Function: ident("f"), Arguments: []Expression{&IntegerLiteral{Value: 2}}},
+			},
+		},
+	}
+
+	var names []string
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok {
+			names = append(names, ident.Value)
+		}
+		return true
+	})
+	if strings.Join(names, " ") != "f x x f" {
+		t.Errorf("wrong identifiers visited. got=%v", names)
+	}
+
+	// skipping function literals skips their parameters and bodies
+	names = nil
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok {
+			names = append(names, ident.Value)
+		}
+		_, fn := node.(*FunctionLiteral)
+		return !fn
+	})
+	if strings.Join(names, " ") != "f f" {
+		t.Errorf("wrong identifiers visited. got=%v", names)
+	}
+}
@@ -0,0 +1,151 @@
+// ast/walk.go
+
+package ast
+
+// visit node and everything below it in source order, calling f with
+// every node. children of a node are skipped when f returns false.
+// optional children are expected to be nil interfaces, not typed nils
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+	case *ForStatement:
+		if n.Key != nil {
+			Inspect(n.Key, f)
+		}
+		Inspect(n.Value, f)
+		Inspect(n.Iterable, f)
+		if n.Body != nil {
+			Inspect(n.Body, f)
+		}
+	case *LetStatement:
+		if n.Name != nil {
+			Inspect(n.Name, f)
+		}
+		if n.Pattern != nil {
+			Inspect(n.Pattern, f)
+		}
+		Inspect(n.Value, f)
+	case *StructStatement:
+		Inspect(n.Name, f)
+		for _, field := range n.Fields {
+			Inspect(field, f)
+		}
+	case *ReturnStatement:
+		Inspect(n.ReturnValue, f)
+	case *ExpressionStatement:
+		Inspect(n.Expression, f)
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+	case *PrefixExpression:
+		Inspect(n.Right, f)
+	case *InfixExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *IfExpression:
+		Inspect(n.Condition, f)
+		if n.Consequence != nil {
+			Inspect(n.Consequence, f)
+		}
+		if n.Alternative != nil {
+			Inspect(n.Alternative, f)
+		}
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			// destructured parameters are visited as their pattern
+			if pattern, ok := n.Patterns[p]; ok {
+				Inspect(pattern, f)
+			} else {
+				Inspect(p, f)
+			}
+			if def, ok := n.Defaults[p]; ok {
+				Inspect(def, f)
+			}
+		}
+		if n.Rest != nil {
+			Inspect(n.Rest, f)
+		}
+		if n.Body != nil {
+			Inspect(n.Body, f)
+		}
+	case *CallExpression:
+		Inspect(n.Function, f)
+		for _, a := range n.Arguments {
+			Inspect(a, f)
+		}
+	case *ArrayLiteral:
+		for _, e := range n.Elements {
+			Inspect(e, f)
+		}
+	case *HashLiteral:
+		for _, k := range n.Keys {
+			Inspect(k, f)
+			Inspect(n.Pairs[k], f)
+		}
+	case *IndexExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Index, f)
+	case *MemberExpression:
+		Inspect(n.Object, f)
+		Inspect(n.Property, f)
+	case *AssignExpression:
+		Inspect(n.Target, f)
+		Inspect(n.Value, f)
+	case *YieldExpression:
+		Inspect(n.Value, f)
+	case *SelectExpression:
+		for _, c := range n.Cases {
+			Inspect(c, f)
+		}
+	case *SelectCase:
+		if n.Name != nil {
+			Inspect(n.Name, f)
+		}
+		if n.Channel != nil {
+			Inspect(n.Channel, f)
+		}
+		if n.Value != nil {
+			Inspect(n.Value, f)
+		}
+		Inspect(n.Body, f)
+	case *SliceExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Start, f)
+		Inspect(n.End, f)
+	case *MatchExpression:
+		Inspect(n.Subject, f)
+		for _, arm := range n.Arms {
+			Inspect(arm, f)
+		}
+	case *MatchArm:
+		Inspect(n.Pattern, f)
+		if n.Guard != nil {
+			Inspect(n.Guard, f)
+		}
+		Inspect(n.Body, f)
+	case *LiteralPattern:
+		Inspect(n.Value, f)
+	case *BindingPattern:
+		Inspect(n.Name, f)
+	case *ArrayPattern:
+		for _, el := range n.Elements {
+			Inspect(el, f)
+		}
+		if n.Rest != nil {
+			Inspect(n.Rest, f)
+		}
+	case *HashPattern:
+		for i, key := range n.Keys {
+			Inspect(key, f)
+			Inspect(n.Values[i], f)
+		}
+	}
+}
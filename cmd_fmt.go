@@ -0,0 +1,88 @@
+// cmd_fmt.go
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"intInGo/diag"
+	"intInGo/format"
+	"io"
+	"os"
+)
+
+// monkey fmt [-l] [-w] [-width n] [files]
+//
+// format the given files, or stdin when there are none, and print the
+// result to stdout
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	list := flags.Bool("l", false, "list files whose formatting differs")
+	write := flags.Bool("w", false, "write the result back to the files")
+	width := flags.Int("width", format.DefaultWidth, "line width to wrap lists at")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey fmt [-l] [-w] [-width n] [files]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	opts := format.Options{Width: *width}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		out, diags := format.Source(string(src), opts)
+		if diags != nil {
+			printDiagnostics("<stdin>", string(src), diags)
+			return 1
+		}
+		io.WriteString(os.Stdout, out)
+		return 0
+	}
+
+	status := 0
+	for _, filename := range flags.Args() {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		out, diags := format.Source(string(src), opts)
+		if diags != nil {
+			printDiagnostics(filename, string(src), diags)
+			status = 1
+			continue
+		}
+
+		changed := !bytes.Equal(src, []byte(out))
+		if *list && changed {
+			fmt.Println(filename)
+		}
+		if *write {
+			if changed {
+				if err := os.WriteFile(filename, []byte(out), 0644); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					status = 1
+				}
+			}
+		} else if !*list {
+			io.WriteString(os.Stdout, out)
+		}
+	}
+
+	return status
+}
+
+func printDiagnostics(filename, src string, diags []diag.Diagnostic) {
+	for _, d := range diags {
+		diag.Render(os.Stderr, filename, src, d)
+	}
+}
@@ -0,0 +1,177 @@
+// cmd_lint.go
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"intInGo/diag"
+	"intInGo/lexer"
+	"intInGo/lint"
+	"intInGo/parser"
+	"io"
+	"os"
+	"strings"
+)
+
+// config picked up from the working directory when -config isn't given
+const lintConfigFile = ".monkeylint.json"
+
+// a finding as printed by monkey lint -json
+type lintFinding struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Code      string `json:"code"` // rule name, or diagnostic code for parse errors
+	Severity  string `json:"severity"`
+	Message   string `json:"message"`
+}
+
+// monkey lint [-config file] [-enable rules] [-disable rules] [-json] [-rules] [files]
+//
+// check the given files, or stdin when there are none, exiting with 1
+// when anything was found
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	configFile := flags.String("config", "", "json config turning rules on or off (default "+lintConfigFile+" if present)")
+	enable := flags.String("enable", "", "comma separated rules to turn on")
+	disable := flags.String("disable", "", "comma separated rules to turn off")
+	asJSON := flags.Bool("json", false, "print findings as json")
+	listRules := flags.Bool("rules", false, "list the available rules")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey lint [-config file] [-enable rules] [-disable rules] [-json] [-rules] [files]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *listRules {
+		for _, rule := range lint.Rules() {
+			fmt.Printf("%-20s %s\n", rule.Name, rule.Doc)
+		}
+		return 0
+	}
+
+	config, err := loadLintConfig(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for _, toggle := range []struct {
+		names string
+		on    bool
+	}{{*enable, true}, {*disable, false}} {
+		for _, name := range strings.Split(toggle.names, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			if !knownRule(name) {
+				fmt.Fprintf(os.Stderr, "unknown lint rule %q\n", name)
+				return 2
+			}
+			config.Rules[name] = toggle.on
+		}
+	}
+
+	type input struct {
+		name string
+		src  string
+	}
+	var inputs []input
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		inputs = append(inputs, input{"<stdin>", string(src)})
+	}
+	for _, filename := range flags.Args() {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		inputs = append(inputs, input{filename, string(src)})
+	}
+
+	status := 0
+	findings := []lintFinding{}
+	for _, in := range inputs {
+		p := parser.New(lexer.New(in.src))
+		program := p.ParseProgram()
+
+		// rules expect a complete program, so parse errors are all we report
+		diags := p.Diagnostics()
+		if len(diags) == 0 {
+			diags = lint.Lint(program, config)
+		}
+		if len(diags) != 0 {
+			status = 1
+		}
+
+		for _, d := range diags {
+			if *asJSON {
+				findings = append(findings, lintFinding{
+					File:      in.name,
+					Line:      d.Span.Start.Line,
+					Column:    d.Span.Start.Column,
+					EndLine:   d.Span.End.Line,
+					EndColumn: d.Span.End.Column,
+					Code:      d.Code,
+					Severity:  d.Severity.String(),
+					Message:   d.Message,
+				})
+			} else {
+				diag.Render(os.Stdout, in.name, in.src, d)
+			}
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(findings); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return status
+}
+
+func loadLintConfig(filename string) (lint.Config, error) {
+	if filename == "" {
+		if _, err := os.Stat(lintConfigFile); err != nil {
+			return lint.Config{Rules: map[string]bool{}}, nil
+		}
+		filename = lintConfigFile
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return lint.Config{}, err
+	}
+	defer f.Close()
+
+	config, err := lint.ReadConfig(f)
+	if err != nil {
+		return lint.Config{}, fmt.Errorf("%s: %w", filename, err)
+	}
+	if config.Rules == nil {
+		config.Rules = map[string]bool{}
+	}
+	return config, nil
+}
+
+func knownRule(name string) bool {
+	for _, rule := range lint.Rules() {
+		if rule.Name == name {
+			return true
+		}
+	}
+	return false
+}
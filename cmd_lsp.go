@@ -0,0 +1,25 @@
+// cmd_lsp.go
+
+package main
+
+import (
+	"fmt"
+	"intInGo/lsp"
+	"os"
+)
+
+// monkey lsp
+//
+// serve the language server protocol over stdin and stdout
+func runLSP(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintf(os.Stderr, "usage: monkey lsp\n")
+		return 2
+	}
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey lsp: %s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+// diag/diag.go
+
+package diag
+
+import (
+	"fmt"
+	"intInGo/token"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Info
+	Hint
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Info:
+		return "info"
+	case Hint:
+		return "hint"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// codes identifying the kind of a diagnostic
+const (
+	// lexer
+	IllegalCharacter   = "E0001"
+	UnterminatedString = "E0002"
+
+	// parser
+	UnexpectedToken   = "E0100"
+	MissingExpression = "E0101"
+	InvalidInteger    = "E0102"
+	InvalidAssignment = "E0103"
+	DuplicateField    = "E0104"
+	InvalidPattern    = "E0105"
+	DuplicateBinding  = "E0106"
+	MisplacedKeyword  = "E0107"
+	InvalidSelectCase = "E0108"
+
+	// evaluator
+	RuntimeError       = "E0200"
+	TypeMismatch       = "E0201"
+	UnknownOperator    = "E0202"
+	UndefinedName      = "E0203"
+	NotCallable        = "E0204"
+	UnhashableKey      = "E0205"
+	IndexOutOfRange    = "E0206"
+	WrongArgumentCount = "E0207"
+	UnknownField       = "E0208"
+	UnknownMethod      = "E0209"
+	PatternMismatch    = "E0210"
+	NotIterable        = "E0211"
+	IntegerOverflow    = "E0212"
+	DivisionByZero     = "E0213"
+
+	// resolver
+	DuplicateParameter = "E0300"
+	UnusedBinding      = "E0301"
+	ShadowedParameter  = "E0302"
+
+	// type checker, which also reports evaluator codes for errors it
+	// finds ahead of time
+	IncompatibleTypes = "E0400"
+	UnknownType       = "E0401"
+)
+
+// range of source text, End is exclusive
+type Span struct {
+	Start token.Position
+	End   token.Position
+}
+
+// span covering a single token
+func TokenSpan(tok token.Token) Span {
+	return Span{Start: tok.Pos, End: tok.End}
+}
+
+func (s Span) IsValid() bool { return s.Start.IsValid() }
+
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	Span     Span
+	Message  string
+	Notes    []string
+}
+
+func (d Diagnostic) String() string {
+	if d.Span.IsValid() {
+		return fmt.Sprintf("%s: %s[%s]: %s", d.Span.Start, d.Severity, d.Code, d.Message)
+	}
+	return fmt.Sprintf("%s[%s]: %s", d.Severity, d.Code, d.Message)
+}
+
+// create an error diagnostic
+func Errorf(code string, span Span, format string, a ...interface{}) Diagnostic {
+	return Diagnostic{
+		Severity: Error,
+		Code:     code,
+		Span:     span,
+		Message:  fmt.Sprintf(format, a...),
+	}
+}
+
+// create a warning diagnostic
+func Warningf(code string, span Span, format string, a ...interface{}) Diagnostic {
+	d := Errorf(code, span, format, a...)
+	d.Severity = Warning
+	return d
+}
@@ -0,0 +1,118 @@
+// diag/diag_test.go
+
+package diag
+
+import (
+	"bytes"
+	"intInGo/token"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	source := "let a = 1;\n\tlet b = a + true;\nb"
+
+	tests := []struct {
+		filename string
+		diag     Diagnostic
+		expected string
+	}{
+		{
+			"",
+			Diagnostic{
+				Severity: Error,
+				Code:     TypeMismatch,
+				Span: Span{
+					Start: token.Position{Offset: 22, Line: 2, Column: 12},
+					End:   token.Position{Offset: 23, Line: 2, Column: 13},
+				},
+				Message: "type mismatch: INTEGER + BOOLEAN",
+			},
+			"error[E0201]: type mismatch: INTEGER + BOOLEAN\n" +
+				" --> 2:12\n" +
+				"  |\n" +
+				"2 | \tlet b = a + true;\n" +
+				"  | \t          ^\n",
+		},
+		{
+			"script.mk",
+			Diagnostic{
+				Severity: Warning,
+				Code:     "W0001",
+				Span: Span{
+					Start: token.Position{Offset: 4, Line: 1, Column: 5},
+					End:   token.Position{Offset: 5, Line: 1, Column: 6},
+				},
+				Message: "unused variable a",
+				Notes:   []string{"remove it"},
+			},
+			"warning[W0001]: unused variable a\n" +
+				" --> script.mk:1:5\n" +
+				"  |\n" +
+				"1 | let a = 1;\n" +
+				"  |     ^\n" +
+				"  = note: remove it\n",
+		},
+		{
+			"",
+			Diagnostic{
+				Severity: Error,
+				Code:     UnexpectedToken,
+				Span: Span{
+					Start: token.Position{Offset: 0, Line: 1, Column: 1},
+					End:   token.Position{Offset: 3, Line: 1, Column: 4},
+				},
+				Message: "unexpected let",
+			},
+			"error[E0100]: unexpected let\n" +
+				" --> 1:1\n" +
+				"  |\n" +
+				"1 | let a = 1;\n" +
+				"  | ^^^\n",
+		},
+		{
+			"",
+			Diagnostic{Severity: Error, Code: RuntimeError, Message: "somewhere"},
+			"error[E0200]: somewhere\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Render(&out, tt.filename, source, tt.diag)
+		if out.String() != tt.expected {
+			t.Errorf("wrong rendering.\nwant=%q\ngot= %q", tt.expected, out.String())
+		}
+	}
+}
+
+func TestRenderMultibyte(t *testing.T) {
+	source := `let s = "héllo" + 1;`
+
+	tests := []struct {
+		span     Span
+		expected string
+	}{
+		{
+			Span{
+				Start: token.Position{Offset: 19, Line: 1, Column: 20},
+				End:   token.Position{Offset: 20, Line: 1, Column: 21},
+			},
+			"  |                   ^\n",
+		},
+		{
+			Span{
+				Start: token.Position{Offset: 8, Line: 1, Column: 9},
+				End:   token.Position{Offset: 16, Line: 1, Column: 17},
+			},
+			"  |         ^^^^^^^\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Render(&out, "", source, Diagnostic{Severity: Error, Code: TypeMismatch, Span: tt.span, Message: "m"})
+		if !bytes.HasSuffix(out.Bytes(), []byte(tt.expected)) {
+			t.Errorf("wrong underline.\nwant=%q\ngot= %q", tt.expected, out.String())
+		}
+	}
+}
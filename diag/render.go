@@ -0,0 +1,89 @@
+// diag/render.go
+
+package diag
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// print a diagnostic followed by the offending source line with the span
+// underlined, e.g.
+//
+//	error[E0100]: expected next token to be =, got INT
+//	 --> script.mk:1:7
+//	  |
+//	1 | let x 5;
+//	  |       ^
+func Render(w io.Writer, filename, source string, d Diagnostic) {
+	fmt.Fprintf(w, "%s[%s]: %s\n", d.Severity, d.Code, d.Message)
+
+	if !d.Span.IsValid() {
+		for _, note := range d.Notes {
+			fmt.Fprintf(w, " = note: %s\n", note)
+		}
+		return
+	}
+
+	lines := strings.Split(source, "\n")
+	start := d.Span.Start
+	gutter := strings.Repeat(" ", len(strconv.Itoa(start.Line)))
+
+	if filename != "" {
+		fmt.Fprintf(w, "%s--> %s:%s\n", gutter, filename, start)
+	} else {
+		fmt.Fprintf(w, "%s--> %s\n", gutter, start)
+	}
+
+	if start.Line <= len(lines) {
+		line := strings.TrimRight(lines[start.Line-1], "\r")
+		fmt.Fprintf(w, "%s |\n", gutter)
+		fmt.Fprintf(w, "%d | %s\n", start.Line, line)
+		fmt.Fprintf(w, "%s | %s\n", gutter, underline(line, d.Span))
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(w, "%s = note: %s\n", gutter, note)
+	}
+}
+
+// build the caret line for a span starting on the given line, keeping tabs
+// so the carets stay aligned with the source. span columns are byte offsets,
+// the padding and carets are counted in runes
+func underline(line string, span Span) string {
+	startCol := span.Start.Column - 1
+	if startCol > len(line) {
+		startCol = len(line)
+	}
+
+	// underline to the end of the span, or the end of the line when the span
+	// continues on later lines
+	endCol := startCol + 1
+	if span.End.Line == span.Start.Line && span.End.Column-1 > startCol {
+		endCol = span.End.Column - 1
+	} else if span.End.Line > span.Start.Line && len(line) > startCol {
+		endCol = len(line)
+	}
+
+	var out strings.Builder
+	for _, r := range line[:startCol] {
+		if r == '\t' {
+			out.WriteByte('\t')
+		} else {
+			out.WriteByte(' ')
+		}
+	}
+
+	width := endCol - startCol
+	if endCol <= len(line) {
+		width = utf8.RuneCountInString(line[startCol:endCol])
+	} else {
+		width -= len(line) - startCol - utf8.RuneCountInString(line[startCol:])
+	}
+	out.WriteString(strings.Repeat("^", width))
+
+	return out.String()
+}
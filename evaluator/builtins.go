@@ -2,14 +2,19 @@ package evaluator
 
 import (
 	"fmt"
+	"intInGo/diag"
 	"intInGo/object"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
 )
 
 var builtins = map[string]*object.BuiltIn{
 	"len": &object.BuiltIn{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx *object.Context, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1", len(args))
 			}
 
 			switch arg := args[0].(type) {
@@ -17,15 +22,17 @@ var builtins = map[string]*object.BuiltIn{
 				return &object.Integer{Value: int64(len(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Range:
+				return object.NewBigInt(new(big.Int).SetUint64(arg.Len()))
 			default:
 				return newError("argument to `len` not supported, got %s", args[0].Type())
 			}
 		},
 	},
 	"first": &object.BuiltIn{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx *object.Context, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1", len(args))
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
 				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
@@ -40,9 +47,9 @@ var builtins = map[string]*object.BuiltIn{
 		},
 	},
 	"last": &object.BuiltIn{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx *object.Context, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1", len(args))
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
 				return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
@@ -58,9 +65,9 @@ var builtins = map[string]*object.BuiltIn{
 		},
 	},
 	"rest": &object.BuiltIn{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx *object.Context, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1", len(args))
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
 				return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
@@ -78,9 +85,9 @@ var builtins = map[string]*object.BuiltIn{
 		},
 	},
 	"push": &object.BuiltIn{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx *object.Context, args ...object.Object) object.Object {
 			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2", len(args))
+				return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=2", len(args))
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
 				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
@@ -96,13 +103,208 @@ var builtins = map[string]*object.BuiltIn{
 			return &object.Array{Elements: newElements}
 		},
 	},
+	"range": &object.BuiltIn{
+		Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 3 {
+				return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1..3", len(args))
+			}
+
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				n, ok := arg.(*object.Integer)
+				if !ok {
+					return newError("argument %d to `range` must be INTEGER, got %s", i+1, arg.Type())
+				}
+				bounds[i] = n.Value
+			}
+
+			r := &object.Range{Step: 1}
+			switch len(bounds) {
+			case 1:
+				r.End = bounds[0]
+			case 2:
+				r.Start, r.End = bounds[0], bounds[1]
+			case 3:
+				r.Start, r.End, r.Step = bounds[0], bounds[1], bounds[2]
+			}
+			if r.Step == 0 {
+				return newError("`range` step must not be 0")
+			}
+			return r
+		},
+	},
+	"iter": &object.BuiltIn{
+		Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			it, ok := args[0].(object.Iterable)
+			if !ok {
+				return newError("argument to `iter` not supported, got %s", args[0].Type())
+			}
+			return it.Iter()
+		},
+	},
+	"await": &object.BuiltIn{
+		Fn: await,
+	},
+	"channel": &object.BuiltIn{
+		Fn: channel,
+	},
+	"send": &object.BuiltIn{
+		Fn: send,
+	},
+	"recv": &object.BuiltIn{
+		Fn: recv,
+	},
+	"close": &object.BuiltIn{
+		Fn: closeChannel,
+	},
 	"puts": &object.BuiltIn{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Fprintln(ctx.Stdout, arg.Inspect())
+			}
+
+			return NULL
+		},
+	},
+	"print": &object.BuiltIn{
+		Fn: func(ctx *object.Context, args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				io.WriteString(ctx.Stdout, arg.Inspect())
 			}
 
 			return NULL
 		},
 	},
+	"eprint": &object.BuiltIn{
+		Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+			for _, arg := range args {
+				io.WriteString(ctx.Stderr, arg.Inspect())
+			}
+
+			return NULL
+		},
+	},
+	"format": &object.BuiltIn{
+		Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want at least 1", len(args))
+			}
+			if args[0].Type() != object.STRING_OBJ {
+				return newError("first argument to `format` must be STRING, got %s", args[0].Type())
+			}
+
+			// hand integers, strings and booleans to fmt as native values so
+			// verbs like %d, %q and %t work, everything else as its inspection
+			values := make([]interface{}, len(args)-1)
+			for i, arg := range args[1:] {
+				switch arg := arg.(type) {
+				case *object.Integer:
+					values[i] = arg.Value
+				case *object.BigInt:
+					values[i] = arg.Value
+				case *object.String:
+					values[i] = arg.Value
+				case *object.Boolean:
+					values[i] = arg.Value
+				default:
+					values[i] = arg.Inspect()
+				}
+			}
+
+			return &object.String{Value: fmt.Sprintf(args[0].(*object.String).Value, values...)}
+		},
+	},
+	"input": &object.BuiltIn{
+		Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+			if len(args) == 1 {
+				io.WriteString(ctx.Stdout, args[0].Inspect())
+			}
+
+			line, err := ctx.Stdin.ReadString('\n')
+			if err != nil && line == "" {
+				// nothing left to read
+				return NULL
+			}
+
+			line = strings.TrimSuffix(line, "\n")
+			line = strings.TrimSuffix(line, "\r")
+			return &object.String{Value: line}
+		},
+	},
+	"is_null": &object.BuiltIn{
+		Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			return nativeBoolToBooleanObject(args[0] == NULL)
+		},
+	},
+	"decimal": &object.BuiltIn{
+		Fn: decimal,
+	},
+	"round": &object.BuiltIn{
+		Fn: round,
+	},
+	"int": &object.BuiltIn{
+		Fn: integer,
+	},
+	"json_parse": &object.BuiltIn{
+		Fn: jsonParse,
+	},
+	"json_stringify": &object.BuiltIn{
+		Fn: jsonStringify,
+	},
+	"read_file": &object.BuiltIn{
+		Fn: readFile,
+	},
+	"write_file": &object.BuiltIn{
+		Fn: writeFile,
+	},
+	"append_file": &object.BuiltIn{
+		Fn: appendFile,
+	},
+	"list_dir": &object.BuiltIn{
+		Fn: listDir,
+	},
+	"exists": &object.BuiltIn{
+		Fn: exists,
+	},
+}
+
+// builtin of the context's registry, or of the defaults if it has none
+func lookupBuiltin(ctx *object.Context, name string) (*object.BuiltIn, bool) {
+	if ctx.Builtins != nil {
+		fn, ok := ctx.Builtins[name]
+		return fn, ok
+	}
+	fn, ok := builtins[name]
+	return fn, ok
+}
+
+// a copy of the default builtins, to give a context a registry of its own
+// with builtins added or left out
+func Builtins() map[string]*object.BuiltIn {
+	registry := make(map[string]*object.BuiltIn, len(builtins))
+	for name, fn := range builtins {
+		registry[name] = fn
+	}
+	return registry
+}
+
+// names of all default builtin functions, sorted
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
@@ -0,0 +1,185 @@
+package evaluator
+
+import (
+	"intInGo/ast"
+	"intInGo/diag"
+	"intInGo/object"
+	"reflect"
+)
+
+// spawn calls functions, which evaluate builtins, so it can't be part of
+// the initializer of the builtins
+func init() {
+	builtins["spawn"] = &object.BuiltIn{Fn: spawn}
+}
+
+// spawn(fn, args...) calls fn with args on a goroutine of its own and
+// returns the task to await its result
+func spawn(ctx *object.Context, args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1 or more", len(args))
+	}
+
+	switch args[0].(type) {
+	case *object.Function, *object.BuiltIn, *object.StructType:
+	default:
+		return newCodedError(diag.NotCallable, "argument to `spawn` must be a function, got %s", args[0].Type())
+	}
+	fn, rest := args[0], args[1:]
+	return object.NewTask(func() object.Object {
+		return applyFunction(fn, rest, ctx)
+	})
+}
+
+// await(task) waits for the task and returns its result, errors raised
+// by the task are raised again
+func await(ctx *object.Context, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	task, ok := args[0].(*object.Task)
+	if !ok {
+		return newError("argument to `await` must be TASK, got %s", args[0].Type())
+	}
+	return task.Await()
+}
+
+// channel(cap) makes a channel buffering up to cap values, unbuffered
+// without cap
+func channel(ctx *object.Context, args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=0..1", len(args))
+	}
+
+	capacity := int64(0)
+	if len(args) == 1 {
+		n, ok := args[0].(*object.Integer)
+		if !ok {
+			return newError("argument to `channel` must be INTEGER, got %s", args[0].Type())
+		}
+		if n.Value < 0 {
+			return newError("channel capacity must not be negative, got %d", n.Value)
+		}
+		capacity = n.Value
+	}
+	return object.NewChannel(int(capacity))
+}
+
+func channelArg(name string, args []object.Object, want int) (*object.Channel, *object.Error) {
+	if len(args) != want {
+		return nil, newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=%d", len(args), want)
+	}
+	ch, ok := args[0].(*object.Channel)
+	if !ok {
+		return nil, newError("argument 1 to `%s` must be CHANNEL, got %s", name, args[0].Type())
+	}
+	return ch, nil
+}
+
+func send(ctx *object.Context, args ...object.Object) object.Object {
+	ch, err := channelArg("send", args, 2)
+	if err != nil {
+		return err
+	}
+	if !ch.Send(args[1]) {
+		return newError("send on closed channel")
+	}
+	return NULL
+}
+
+// recv(ch) waits for a value, null once the channel is closed and drained
+func recv(ctx *object.Context, args ...object.Object) object.Object {
+	ch, err := channelArg("recv", args, 1)
+	if err != nil {
+		return err
+	}
+	value, ok := ch.Recv()
+	if !ok {
+		return NULL
+	}
+	return value
+}
+
+func closeChannel(ctx *object.Context, args ...object.Object) object.Object {
+	ch, err := channelArg("close", args, 1)
+	if err != nil {
+		return err
+	}
+	if !ch.Close() {
+		return newError("close of closed channel")
+	}
+	return NULL
+}
+
+// wait for the first case whose channel operation can go ahead, or take
+// the default case if none can right away. like a match arm the body of
+// the case gets an environment of its own for the received value
+func evalSelectExpression(
+	node *ast.SelectExpression,
+	env *object.Environment,
+) object.Object {
+	// channels sent on can't be closed until the select is done
+	var sending []*object.Channel
+	defer func() {
+		for _, ch := range sending {
+			ch.UnlockSend()
+		}
+	}()
+
+	cases := make([]reflect.SelectCase, len(node.Cases))
+	for i, c := range node.Cases {
+		if c.Channel == nil {
+			cases[i] = reflect.SelectCase{Dir: reflect.SelectDefault}
+			continue
+		}
+
+		obj := Eval(c.Channel, env)
+		if isError(obj) {
+			return obj
+		}
+		ch, ok := obj.(*object.Channel)
+		if !ok {
+			return newError("cannot %s on %s, not a channel", c.Op.Literal, obj.Type())
+		}
+
+		if c.Value == nil {
+			cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.C)}
+			continue
+		}
+		value := Eval(c.Value, env)
+		if isError(value) {
+			return value
+		}
+		if !ch.LockSend() {
+			return newError("send on closed channel")
+		}
+		sending = append(sending, ch)
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(ch.C), Send: reflect.ValueOf(value)}
+	}
+
+	// a channel closed while waiting to send on it ends the select
+	for _, ch := range sending {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.Done())})
+	}
+
+	chosen, received, ok := reflect.Select(cases)
+	for _, ch := range sending {
+		ch.UnlockSend()
+	}
+	sending = nil
+	if chosen >= len(node.Cases) {
+		return newError("send on closed channel")
+	}
+	c := node.Cases[chosen]
+
+	caseEnv := object.NewEnclosedEnvironment(env)
+	if c.Name != nil {
+		var value object.Object = NULL
+		if ok {
+			value = received.Interface().(object.Object)
+		}
+		caseEnv.Set(c.Name.Value, value)
+	}
+	return Eval(c.Body, caseEnv)
+}
@@ -0,0 +1,146 @@
+package evaluator
+
+import (
+	"intInGo/diag"
+	"intInGo/object"
+	"math/big"
+)
+
+// arithmetic and comparison of decimals, with integers taken as decimals
+// without digits after the point. sums, differences and products are
+// exact, quotients are rounded to the context's DecimalScale
+func evalDecimalInfixExpression(
+	operator string,
+	left, right object.Object,
+	ctx *object.Context,
+) object.Object {
+	leftVal, _ := object.DecimalValue(left)
+	rightVal, _ := object.DecimalValue(right)
+
+	switch operator {
+	case "+":
+		return leftVal.Add(rightVal)
+	case "-":
+		return leftVal.Sub(rightVal)
+	case "*":
+		return leftVal.Mul(rightVal)
+	case "/":
+		if rightVal.Value.Sign() == 0 {
+			return divisionByZero(left, right)
+		}
+		return leftVal.Quo(rightVal, ctx.DecimalScale, ctx.Rounding)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newCodedError(diag.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// integers, big integers or decimals
+func isNumber(obj object.Object) bool {
+	return isInteger(obj) || obj.Type() == object.DECIMAL_OBJ
+}
+
+// convert an integer, decimal or string like "12.50" to a decimal, with
+// the given number of digits after the point if there is a second argument
+func decimal(ctx *object.Context, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	var value *object.Decimal
+	switch arg := args[0].(type) {
+	case *object.String:
+		d, ok := object.ParseDecimal(arg.Value)
+		if !ok {
+			return newError("decimal: invalid decimal %q", arg.Value)
+		}
+		value = d
+	default:
+		d, ok := object.DecimalValue(arg)
+		if !ok {
+			return newError("argument to `decimal` must be INTEGER, DECIMAL or STRING, got %s", arg.Type())
+		}
+		value = d
+	}
+
+	if len(args) == 1 {
+		return value
+	}
+	scale, errObj := scaleArg("decimal", args[1])
+	if errObj != nil {
+		return errObj
+	}
+	return value.Rescale(scale, ctx.Rounding)
+}
+
+// round a number to a decimal with scale digits after the point, none by
+// default, by the named rounding mode or else the context's
+func round(ctx *object.Context, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1..3", len(args))
+	}
+
+	value, ok := object.DecimalValue(args[0])
+	if !ok {
+		return newError("first argument to `round` must be INTEGER or DECIMAL, got %s", args[0].Type())
+	}
+	scale := 0
+	if len(args) >= 2 {
+		var errObj *object.Error
+		if scale, errObj = scaleArg("round", args[1]); errObj != nil {
+			return errObj
+		}
+	}
+	mode := ctx.Rounding
+	if len(args) == 3 {
+		name, ok := args[2].(*object.String)
+		if !ok {
+			return newError("third argument to `round` must be STRING, got %s", args[2].Type())
+		}
+		if mode, ok = object.LookupRoundingMode(name.Value); !ok {
+			return newError("round: unknown rounding mode %q", name.Value)
+		}
+	}
+	return value.Rescale(scale, mode)
+}
+
+func scaleArg(name string, arg object.Object) (int, *object.Error) {
+	scale, ok := arg.(*object.Integer)
+	if !ok {
+		return 0, newError("scale argument to `%s` must be INTEGER, got %s", name, arg.Type())
+	}
+	if scale.Value < 0 || scale.Value > 1000 {
+		return 0, newError("%s: scale must be between 0 and 1000, got %d", name, scale.Value)
+	}
+	return int(scale.Value), nil
+}
+
+// convert a decimal, dropping the digits after the point, or a string like
+// "42" to an integer
+func integer(ctx *object.Context, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer, *object.BigInt:
+		return arg
+	case *object.Decimal:
+		return bigResult(arg.Rescale(0, object.RoundDown).Value, ctx, arg.Inspect())
+	case *object.String:
+		value, ok := new(big.Int).SetString(arg.Value, 10)
+		if !ok {
+			return newError("int: invalid integer %q", arg.Value)
+		}
+		return bigResult(value, ctx, arg.Value)
+	default:
+		return newError("argument to `int` must be INTEGER, DECIMAL or STRING, got %s", arg.Type())
+	}
+}
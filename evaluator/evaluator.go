@@ -3,18 +3,137 @@ package evaluator
 import (
 	"fmt"
 	"intInGo/ast"
+	"intInGo/diag"
 	"intInGo/object"
+	"intInGo/token"
+	"math"
+	"math/big"
 )
 
 var (
 	NULL  = &object.Null{}
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
+
+	BREAK    = &object.LoopControl{Kind: object.BREAK_OBJ}
+	CONTINUE = &object.LoopControl{Kind: object.CONTINUE_OBJ}
 )
 
 func Eval(
 	node ast.Node,
 	env *object.Environment,
+) object.Object {
+	return locate(eval(node, env), node)
+}
+
+// point errors at the innermost node they were raised in
+func locate(result object.Object, node ast.Node) object.Object {
+	if err, ok := result.(*object.Error); ok && !err.Span.IsValid() {
+		if tok, ok := errorToken(node); ok {
+			err.Span = diag.TokenSpan(tok)
+		}
+	}
+	return result
+}
+
+// evaluate a node in tail position, where its value is what the function
+// it is in returns. calls there aren't made but returned as tail calls,
+// which applyFunction makes once the function is done
+func evalTail(
+	node ast.Node,
+	env *object.Environment,
+) object.Object {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env, true)
+
+	case *ast.ExpressionStatement:
+		return evalTail(node.Expression, env)
+
+	case *ast.IfExpression:
+		return evalIfExpression(node, env, true)
+
+	case *ast.MatchExpression:
+		return locate(evalMatchExpression(node, env, true), node)
+
+	case *ast.CallExpression:
+		if _, ok := node.Function.(*ast.MemberExpression); ok {
+			break
+		}
+		function := Eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		if node.Optional && function == NULL {
+			return NULL
+		}
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		return &object.TailCall{Fn: function, Args: args, Span: diag.TokenSpan(node.Token)}
+	}
+
+	return Eval(node, env)
+}
+
+// evaluate a branch of an if or match, in tail position when the if or
+// match itself is
+func evalBranch(
+	node ast.Node,
+	env *object.Environment,
+	tail bool,
+) object.Object {
+	if tail {
+		return evalTail(node, env)
+	}
+	return Eval(node, env)
+}
+
+// token runtime errors raised while evaluating a node are reported at,
+// nodes not listed here leave that to their closest listed ancestor
+func errorToken(node ast.Node) (token.Token, bool) {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		return node.Token, true
+	case *ast.PrefixExpression:
+		return node.Token, true
+	case *ast.InfixExpression:
+		return node.Token, true
+	case *ast.CallExpression:
+		return node.Token, true
+	case *ast.IndexExpression:
+		return node.Token, true
+	case *ast.SliceExpression:
+		return node.Token, true
+	case *ast.HashLiteral:
+		return node.Token, true
+	case *ast.MatchExpression:
+		return node.Token, true
+	case *ast.ForStatement:
+		return node.Token, true
+	case *ast.YieldExpression:
+		return node.Token, true
+	case *ast.SelectExpression:
+		return node.Token, true
+	case *ast.MemberExpression:
+		return node.Property.Token, true
+	case *ast.AssignExpression:
+		if member, ok := node.Target.(*ast.MemberExpression); ok {
+			return member.Property.Token, true
+		}
+		return node.Token, true
+	case *ast.BadExpression:
+		return node.Token, true
+	case *ast.BadStatement:
+		return node.Token, true
+	}
+	return token.Token{}, false
+}
+
+func eval(
+	node ast.Node,
+	env *object.Environment,
 ) object.Object {
 	switch node := node.(type) {
 
@@ -27,11 +146,25 @@ func Eval(
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{
+			Parameters: params,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
+			Patterns:   node.Patterns,
+			Env:        env,
+			Body:       body,
+			Generator:  node.Generator,
+		}
 
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return bigResult(node.Big, env.Context(), node.Token.Literal)
+		}
 		return &object.Integer{Value: node.Value}
 
+	case *ast.DecimalLiteral:
+		return &object.Decimal{Value: node.Value, Scale: node.Scale}
+
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
@@ -48,69 +181,139 @@ func Eval(
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
+	case *ast.NullLiteral:
+		return NULL
+
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return evalIfExpression(node, env, false)
 
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return evalPrefixExpression(node.Operator, right, env.Context())
 
 	case *ast.InfixExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
+		// the right side of a ?? is only evaluated when the left is null
+		if node.Operator == "??" {
+			if left != NULL {
+				return left
+			}
+			return Eval(node.Right, env)
+		}
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return evalInfixExpression(node.Operator, left, right, env.Context())
 
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
+		if node.Optional && left == NULL {
+			return NULL
+		}
 		index := Eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		return evalIndexExpression(left, index, env.Context())
+
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+
+	case *ast.MemberExpression:
+		obj := Eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		s, err := structField(obj, node.Property.Value)
+		if err != nil {
+			return err
+		}
+		return s.Get(node.Property.Value)
+
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env, false)
+
+	case *ast.YieldExpression:
+		return evalYieldExpression(node, env)
+
+	case *ast.SelectExpression:
+		return evalSelectExpression(node, env)
 
 	case *ast.CallExpression:
+		if member, ok := node.Function.(*ast.MemberExpression); ok {
+			return evalMethodCall(node, member, env)
+		}
 		function := Eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
+		// f?() is null without evaluating the arguments when f is
+		if node.Optional && function == NULL {
+			return NULL
+		}
 		args := evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return applyFunction(function, args, env.Context())
 
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return evalBlockStatement(node, env, false)
 
+	// a return leaves the function right away, so wherever it is its value
+	// is in tail position
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := evalTail(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 
+	case *ast.BadExpression, *ast.BadStatement:
+		return newError("invalid syntax near %q", node.TokenLiteral())
+
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
+		if node.Pattern != nil {
+			return bindPattern(node.Pattern, val, env)
+		}
 		env.Set(node.Name.Value, val)
+
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
+	case *ast.StructStatement:
+		def := &object.StructType{Name: node.Name.Value}
+		for _, field := range node.Fields {
+			def.Fields = append(def.Fields, field.Value)
+		}
+		env.Set(node.Name.Value, def)
 	}
 
 	return nil
@@ -127,7 +330,7 @@ func evalProgram(
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
-			return result.Value
+			return trampoline(result.Value, env.Context())
 		case *object.Error:
 			return result
 		}
@@ -139,16 +342,18 @@ func evalProgram(
 func evalBlockStatement(
 	block *ast.BlockStatement,
 	env *object.Environment,
+	tail bool,
 ) object.Object {
 	var result object.Object
 
-	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+	for i, statement := range block.Statements {
+		result = evalBranch(statement, env, tail && i == len(block.Statements)-1)
 
 		if result != nil {
 			rt := result.Type()
 
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ ||
+				rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
 		}
@@ -177,30 +382,114 @@ func evalExpressions(
 func applyFunction(
 	fn object.Object,
 	args []object.Object,
+	ctx *object.Context,
+) object.Object {
+	return trampoline(apply(fn, args, ctx), ctx)
+}
+
+// make the tail calls functions return until one returns a value, each
+// from here instead of from within the function that returned it
+func trampoline(result object.Object, ctx *object.Context) object.Object {
+	for {
+		call, ok := result.(*object.TailCall)
+		if !ok {
+			return result
+		}
+		result = apply(call.Fn, call.Args, ctx)
+		if err, ok := result.(*object.Error); ok && !err.Span.IsValid() {
+			err.Span = call.Span
+		}
+	}
+}
+
+// call fn, a user function may return a tail call instead of a value
+func apply(
+	fn object.Object,
+	args []object.Object,
+	ctx *object.Context,
 ) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+		if fn.Generator {
+			return newGenerator(fn, extendedEnv)
+		}
+		evaluated := evalTail(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 
 	case *object.BuiltIn:
-		return fn.Fn(args...)
+		return fn.Fn(ctx, args...)
+
+	case *object.StructType:
+		if len(args) != len(fn.Fields) {
+			return newCodedError(diag.WrongArgumentCount,
+				"wrong number of arguments: want=%d, got=%d", len(fn.Fields), len(args))
+		}
+		return object.NewStruct(fn, args)
 
 	default:
-		return newError("not a function: %s", fn.Type())
+		return newCodedError(diag.NotCallable, "not a function: %s", fn.Type())
 	}
 }
 
+// bind arguments to parameters in a new environment. omitted optional
+// parameters get their default, evaluated in that environment so it can
+// refer to the parameters before it
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
-) *object.Environment {
+) (*object.Environment, *object.Error) {
+	required := len(fn.Parameters) - len(fn.Defaults)
+	if len(args) < required || (fn.Rest == nil && len(args) > len(fn.Parameters)) {
+		return nil, newCodedError(diag.WrongArgumentCount,
+			"wrong number of arguments: want=%s, got=%d", arity(fn), len(args))
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		var val object.Object
+		if paramIdx < len(args) {
+			val = args[paramIdx]
+		} else {
+			val = Eval(fn.Defaults[param], env)
+			if err, ok := val.(*object.Error); ok {
+				return nil, err
+			}
+		}
+
+		if pattern, ok := fn.Patterns[param]; ok {
+			if err := bindPattern(pattern, val, env); err != nil {
+				return nil, err.(*object.Error)
+			}
+			continue
+		}
+		env.Set(param.Value, val)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+	return env, nil
+}
+
+// accepted argument counts, like 2, 1..2 or 1 or more
+func arity(fn *object.Function) string {
+	required := len(fn.Parameters) - len(fn.Defaults)
+	switch {
+	case fn.Rest != nil:
+		return fmt.Sprintf("%d or more", required)
+	case required < len(fn.Parameters):
+		return fmt.Sprintf("%d..%d", required, len(fn.Parameters))
+	default:
+		return fmt.Sprintf("%d", required)
 	}
-	return env
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -224,23 +513,24 @@ func evalIdentifier(
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := lookupBuiltin(env.Context(), node.Value); ok {
 		return builtin
 	}
-	return newError("identifier not found: " + node.Value)
+	return newCodedError(diag.UndefinedName, "identifier not found: %s", node.Value)
 }
 
 func evalPrefixExpression(
 	operator string,
 	right object.Object,
+	ctx *object.Context,
 ) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
-		return evalMinusPrefixOperatorExpression(right)
+		return evalMinusPrefixOperatorExpression(right, ctx)
 	default:
-		return newError("unkown operator: %s%s", operator, right.Type())
+		return newCodedError(diag.UnknownOperator, "unkown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -257,40 +547,134 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+func evalMinusPrefixOperatorExpression(right object.Object, ctx *object.Context) object.Object {
+	if integer, ok := right.(*object.Integer); ok && integer.Value != math.MinInt64 {
+		return &object.Integer{Value: -integer.Value}
+	}
+	if d, ok := right.(*object.Decimal); ok {
+		return d.Neg()
+	}
+	value, ok := object.BigValue(right)
+	if !ok {
+		return newCodedError(diag.UnknownOperator, "unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+	return bigResult(new(big.Int).Neg(value), ctx, "-("+right.Inspect()+")")
 }
 
 func evalInfixExpression(
 	operator string,
 	left, right object.Object,
+	ctx *object.Context,
 ) object.Object {
 	switch {
-	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(operator, left, right)
+	case isInteger(left) && isInteger(right):
+		return evalIntegerInfixExpression(operator, left, right, ctx)
+	case isNumber(left) && isNumber(right):
+		return evalDecimalInfixExpression(operator, left, right, ctx)
+	case left.Type() == object.STRUCT_OBJ && right.Type() == object.STRUCT_OBJ:
+		return evalStructInfixExpression(operator, left, right)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
 		return nativeBoolToBooleanObject(left != right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newCodedError(diag.TypeMismatch, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newCodedError(diag.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
+// structs are equal when they're of the same type and their fields are
+func evalStructInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	switch operator {
+	case "==":
+		return nativeBoolToBooleanObject(objectsEqual(left, right))
+	case "!=":
+		return nativeBoolToBooleanObject(!objectsEqual(left, right))
+	default:
+		return newCodedError(diag.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// integers and strings compare by value and structs field by field,
+// everything else by identity like the == operator does
+func objectsEqual(a, b object.Object) bool {
+	switch a := a.(type) {
+	case *object.Integer:
+		b, ok := b.(*object.Integer)
+		return ok && a.Value == b.Value
+	case *object.BigInt:
+		b, ok := b.(*object.BigInt)
+		return ok && a.Value.Cmp(b.Value) == 0
+	case *object.Decimal:
+		b, ok := b.(*object.Decimal)
+		return ok && a.Cmp(b) == 0
+	case *object.String:
+		b, ok := b.(*object.String)
+		return ok && a.Value == b.Value
+	case *object.Struct:
+		b, ok := b.(*object.Struct)
+		if !ok || a.Def != b.Def {
+			return false
+		}
+		for _, field := range a.Def.Fields {
+			if !objectsEqual(a.Get(field), b.Get(field)) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// the struct obj is, if it has the named field
+func structField(obj object.Object, name string) (*object.Struct, *object.Error) {
+	s, ok := obj.(*object.Struct)
+	if !ok {
+		return nil, newCodedError(diag.UnknownField, "cannot access field %s of %s", name, obj.Type())
+	}
+	if !s.Def.HasField(name) {
+		return nil, newCodedError(diag.UnknownField, "%s has no field %s", s.Def.Name, name)
+	}
+	return s, nil
+}
+
+func evalAssignExpression(
+	node *ast.AssignExpression,
+	env *object.Environment,
+) object.Object {
+	member, ok := node.Target.(*ast.MemberExpression)
+	if !ok {
+		return newCodedError(diag.InvalidAssignment, "cannot assign to %s", node.Target.String())
+	}
+
+	obj := Eval(member.Object, env)
+	if isError(obj) {
+		return obj
+	}
+	s, err := structField(obj, member.Property.Value)
+	if err != nil {
+		return err
+	}
+
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+	return s.Set(member.Property.Value, val)
+}
+
 func evalStringInfixExpression(
 	operator string,
 	left, right object.Object,
 ) object.Object {
 	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newCodedError(diag.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 
 	leftVal := left.(*object.String).Value
@@ -298,21 +682,51 @@ func evalStringInfixExpression(
 	return &object.String{Value: leftVal + rightVal}
 }
 
+// integers or big integers
+func isInteger(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.BIGINT_OBJ
+}
+
+// arithmetic on int64s, switching to big integers for big operands and
+// results that overflow
 func evalIntegerInfixExpression(
 	operator string,
 	left, right object.Object,
+	ctx *object.Context,
 ) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if !lok || !rok {
+		return evalBigIntInfixExpression(operator, left, right, ctx)
+	}
+	leftVal, rightVal := l.Value, r.Value
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		sum := leftVal + rightVal
+		if (sum > leftVal) != (rightVal > 0) {
+			return evalBigIntInfixExpression(operator, left, right, ctx)
+		}
+		return &object.Integer{Value: sum}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		diff := leftVal - rightVal
+		if (diff < leftVal) != (rightVal > 0) {
+			return evalBigIntInfixExpression(operator, left, right, ctx)
+		}
+		return &object.Integer{Value: diff}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		product := leftVal * rightVal
+		if leftVal != 0 && (product/leftVal != rightVal || (leftVal == -1 && rightVal == math.MinInt64)) {
+			return evalBigIntInfixExpression(operator, left, right, ctx)
+		}
+		return &object.Integer{Value: product}
 	case "/":
+		if rightVal == 0 {
+			return divisionByZero(left, right)
+		}
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return evalBigIntInfixExpression(operator, left, right, ctx)
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
@@ -323,14 +737,64 @@ func evalIntegerInfixExpression(
 	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newCodedError(diag.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
-func evalIndexExpression(left, index object.Object) object.Object {
+func evalBigIntInfixExpression(
+	operator string,
+	left, right object.Object,
+	ctx *object.Context,
+) object.Object {
+	leftVal, _ := object.BigValue(left)
+	rightVal, _ := object.BigValue(right)
+	operation := fmt.Sprintf("%s %s %s", left.Inspect(), operator, right.Inspect())
+
+	switch operator {
+	case "+":
+		return bigResult(new(big.Int).Add(leftVal, rightVal), ctx, operation)
+	case "-":
+		return bigResult(new(big.Int).Sub(leftVal, rightVal), ctx, operation)
+	case "*":
+		return bigResult(new(big.Int).Mul(leftVal, rightVal), ctx, operation)
+	case "/":
+		if rightVal.Sign() == 0 {
+			return divisionByZero(left, right)
+		}
+		return bigResult(new(big.Int).Quo(leftVal, rightVal), ctx, operation)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newCodedError(diag.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func divisionByZero(left, right object.Object) *object.Error {
+	return newCodedError(diag.DivisionByZero, "division by zero: %s / %s", left.Inspect(), right.Inspect())
+}
+
+// an integer holding value, which is a big integer unless it fits in an
+// int64. with StrictOverflow that is an error instead, operation
+// describes what overflowed
+func bigResult(value *big.Int, ctx *object.Context, operation string) object.Object {
+	if !value.IsInt64() && ctx.StrictOverflow {
+		return newCodedError(diag.IntegerOverflow, "integer overflow: %s", operation)
+	}
+	return object.NewBigInt(value)
+}
+
+func evalIndexExpression(left, index object.Object, ctx *object.Context) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
-		return evalArrayIndexExpression(left, index)
+		return evalArrayIndexExpression(left, index, ctx)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index, ctx)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 
@@ -344,7 +808,7 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newCodedError(diag.UnhashableKey, "unusable as hash key: %s", index.Type())
 	}
 
 	pair, ok := hashObject.Pairs[key.HashKey()]
@@ -355,25 +819,132 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	return pair.Value
 }
 
-func evalArrayIndexExpression(array, index object.Object) object.Object {
+func evalArrayIndexExpression(array, index object.Object, ctx *object.Context) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
 
-	if idx < 0 || idx > max {
-		return NULL
+	idx, ok := normalizeIndex(index.(*object.Integer).Value, len(arrayObject.Elements))
+	if !ok {
+		return outOfRange(index, len(arrayObject.Elements), ctx)
 	}
 
 	return arrayObject.Elements[idx]
 }
 
+func evalStringIndexExpression(str, index object.Object, ctx *object.Context) object.Object {
+	value := str.(*object.String).Value
+
+	idx, ok := normalizeIndex(index.(*object.Integer).Value, len(value))
+	if !ok {
+		return outOfRange(index, len(value), ctx)
+	}
+
+	return &object.String{Value: value[idx : idx+1]}
+}
+
+// count negative indices from the end (-1 is the last element) and report
+// whether the result lies within [0, length)
+func normalizeIndex(idx int64, length int) (int64, bool) {
+	if idx < 0 {
+		idx += int64(length)
+	}
+	return idx, idx >= 0 && idx < int64(length)
+}
+
+func outOfRange(index object.Object, length int, ctx *object.Context) object.Object {
+	if ctx.StrictIndexing {
+		return newCodedError(diag.IndexOutOfRange, "index out of range: %s (length %d)", index.Inspect(), length)
+	}
+	return NULL
+}
+
+func evalSliceExpression(
+	node *ast.SliceExpression,
+	env *object.Environment,
+) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if node.Optional && left == NULL {
+		return NULL
+	}
+
+	var length int64
+	switch left := left.(type) {
+	case *object.Array:
+		length = int64(len(left.Elements))
+	case *object.String:
+		length = int64(len(left.Value))
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	start, err := evalSliceBound(node.Start, env, 0, length)
+	if err != nil {
+		return err
+	}
+	end, err := evalSliceBound(node.End, env, length, length)
+	if err != nil {
+		return err
+	}
+	if start > end {
+		start = end
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		elements := make([]object.Object, end-start)
+		copy(elements, left.Elements[start:end])
+		return &object.Array{Elements: elements}
+	default:
+		return &object.String{Value: left.(*object.String).Value[start:end]}
+	}
+}
+
+// evaluate one bound of a slice, counting negative bounds from the end and
+// clamping the result into [0, length]
+func evalSliceBound(
+	node ast.Expression,
+	env *object.Environment,
+	omitted, length int64,
+) (int64, *object.Error) {
+	if node == nil {
+		return omitted, nil
+	}
+
+	bound := Eval(node, env)
+	if isError(bound) {
+		return 0, bound.(*object.Error)
+	}
+
+	integer, ok := bound.(*object.Integer)
+	if !ok {
+		return 0, newError("slice index must be INTEGER, got %s", bound.Type())
+	}
+
+	idx := integer.Value
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 {
+		return 0, nil
+	}
+	if idx > length {
+		return length, nil
+	}
+	return idx, nil
+}
+
 func evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
-	for keyNode, valueNode := range node.Pairs {
+	// evaluate pairs in source order
+	for _, keyNode := range node.Keys {
+		valueNode := node.Pairs[keyNode]
+
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
@@ -381,7 +952,7 @@ func evalHashLiteral(
 
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newCodedError(diag.UnhashableKey, "unusable as hash key: %s", key.Type())
 		}
 
 		value := Eval(valueNode, env)
@@ -399,6 +970,7 @@ func evalHashLiteral(
 func evalIfExpression(
 	ie *ast.IfExpression,
 	env *object.Environment,
+	tail bool,
 ) object.Object {
 	condition := Eval(ie.Condition, env)
 
@@ -407,9 +979,9 @@ func evalIfExpression(
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return evalBranch(ie.Consequence, env, tail)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return evalBranch(ie.Alternative, env, tail)
 	} else {
 		return NULL
 	}
@@ -436,5 +1008,9 @@ func isError(obj object.Object) bool {
 }
 
 func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+	return newCodedError(diag.RuntimeError, format, a...)
+}
+
+func newCodedError(code string, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Code: code}
 }
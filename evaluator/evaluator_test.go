@@ -1,9 +1,17 @@
 package evaluator
 
 import (
+	"bytes"
+	"fmt"
+	"intInGo/diag"
 	"intInGo/lexer"
 	"intInGo/object"
 	"intInGo/parser"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
 	"testing"
 )
 
@@ -99,6 +107,11 @@ func testEval(input string) object.Object {
 	return Eval(program, env)
 }
 
+func testEvalWithContext(input string, ctx *object.Context) object.Object {
+	p := parser.New(lexer.New(input))
+	return Eval(p.ParseProgram(), object.NewEnvironmentWithContext(ctx))
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 	evaluated := testEval(input)
@@ -176,6 +189,42 @@ addTwo(2);`
 	testIntegerObject(t, testEval(input), 4)
 }
 
+// recursion through tail calls runs in constant stack, so it goes far
+// deeper than the stack allowed here would without
+func TestTailCalls(t *testing.T) {
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } }; count(100000, 0)", 100000},
+		{"let sum = fn(n, acc) { if (n == 0) { return acc; } return sum(n - 1, acc + n); }; sum(100000, 0)", 5000050000},
+		{"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(100001)", false},
+		{`let down = fn(n) { match (n) { 0 => "done", _ => down(n - 1) } }; down(100000)`, "done"},
+		{"let f = fn(n) { for (i in range(1)) { if (n > 0) { return f(n - 1) } } n }; f(100000)", 0},
+		{"let f = fn(n) { let m = n - 1; if (m < 0) { return m } f(m) }; f(100000)", -1},
+		{"let f = fn(n) { if (n == 0) { len } else { f(n - 1) } }; f(100000)([1, 2])", 2},
+		{"let f = fn(n) { if (n == 0) { 1 } else { n * f(n - 1) } }; f(10)", 3628800},
+		{"return len([1, 2, 3]); 4", 3},
+		{"let g = fn() { yield 1; return len(1) }; g().collect()", errorMessage("argument to `len` not supported, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -252,6 +301,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -267,6 +324,822 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"abc"[0]`, "a"},
+		{`"abc"[2]`, "c"},
+		{`"abc"[-1]`, "c"},
+		{`"abc"[3]`, nil},
+		{`"abc"[-4]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := tt.expected.(string)
+		if !ok {
+			testNullObject(t, evaluated)
+			continue
+		}
+
+		result, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if result.Value != str {
+			t.Errorf("String has wrong value. want=%q, got=%q", str, result.Value)
+		}
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		typ      object.ObjectType
+	}{
+		{"let f = fn(n) { if (n < 2) { 1 } else { n * f(n - 1) } }; f(30)", "265252859812191058636308480000000", object.BIGINT_OBJ},
+		{"9223372036854775807 + 1", "9223372036854775808", object.BIGINT_OBJ},
+		{"9223372036854775807 + 1 - 1", "9223372036854775807", object.INTEGER_OBJ},
+		{"-9223372036854775807 - 1 - 1", "-9223372036854775809", object.BIGINT_OBJ},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808", object.BIGINT_OBJ},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808", object.BIGINT_OBJ},
+		{"-1 * (-9223372036854775807 - 1)", "9223372036854775808", object.BIGINT_OBJ},
+		{"-9223372036854775808", "-9223372036854775808", object.INTEGER_OBJ},
+		{"4294967296 * 4294967296", "18446744073709551616", object.BIGINT_OBJ},
+		{"99999999999999999999", "99999999999999999999", object.BIGINT_OBJ},
+		{"99999999999999999999 / 99999999999999999999", "1", object.INTEGER_OBJ},
+		{"99999999999999999999 * 0", "0", object.INTEGER_OBJ},
+		{"99999999999999999999 > 1", "true", object.BOOLEAN_OBJ},
+		{"99999999999999999999 < -99999999999999999999", "false", object.BOOLEAN_OBJ},
+		{"9223372036854775807 + 1 == 9223372036854775808", "true", object.BOOLEAN_OBJ},
+		{"99999999999999999999 != 99999999999999999999", "false", object.BOOLEAN_OBJ},
+		{"99999999999999999999 == 1", "false", object.BOOLEAN_OBJ},
+		{`{9223372036854775808: "big"}[9223372036854775807 + 1]`, "big", object.STRING_OBJ},
+		{`match (9223372036854775807 + 1) { 9223372036854775808 => "matched", _ => "no" }`, "matched", object.STRING_OBJ},
+		{`format("%d", 99999999999999999999 + 1)`, "100000000000000000000", object.STRING_OBJ},
+		{`json_stringify([99999999999999999999])`, "[99999999999999999999]", object.STRING_OBJ},
+		{`json_parse("99999999999999999999") + 1`, "100000000000000000000", object.BIGINT_OBJ},
+		{`99999999999999999999 + "a"`, "type mismatch: BIGINT + STRING", object.ERROR_OBJ},
+		{"10 / 0", "division by zero: 10 / 0", object.ERROR_OBJ},
+		{"(9223372036854775807 + 1) / 0", "division by zero: 9223372036854775808 / 0", object.ERROR_OBJ},
+		{"99999999999999999999999 / 0", "division by zero: 99999999999999999999999 / 0", object.ERROR_OBJ},
+		{"(-9223372036854775807 - 1) / 0", "division by zero: -9223372036854775808 / 0", object.ERROR_OBJ},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Type() != tt.typ {
+			t.Errorf("wrong type for %q. want=%s, got=%s (%s)", tt.input, tt.typ, evaluated.Type(), evaluated.Inspect())
+			continue
+		}
+		if evaluated.Type() == object.ERROR_OBJ {
+			testErrorObject(t, evaluated, tt.expected)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong value for %q. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestDecimals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		typ      object.ObjectType
+	}{
+		{"12.50d", "12.50", object.DECIMAL_OBJ},
+		{"-12.50d", "-12.50", object.DECIMAL_OBJ},
+		{"0.10d + 0.20d", "0.30", object.DECIMAL_OBJ},
+		{"1.5d + 2", "3.5", object.DECIMAL_OBJ},
+		{"2 - 0.25d", "1.75", object.DECIMAL_OBJ},
+		{"19.99d * 3", "59.97", object.DECIMAL_OBJ},
+		{"1.25d * 1.25d", "1.5625", object.DECIMAL_OBJ},
+		{"10.00d / 3", "3.3333333333", object.DECIMAL_OBJ},
+		{"2.50d / 0.5d", "5.0000000000", object.DECIMAL_OBJ},
+		{"1d / 8", "0.1250000000", object.DECIMAL_OBJ},
+		{"1d / 3d", "0.3333333333", object.DECIMAL_OBJ},
+		{"99999999999999999999 + 0.5d", "99999999999999999999.5", object.DECIMAL_OBJ},
+		{"1.5d == 1.50d", "true", object.BOOLEAN_OBJ},
+		{"1.0d == 1", "true", object.BOOLEAN_OBJ},
+		{"1.5d != 1.5d", "false", object.BOOLEAN_OBJ},
+		{"0.1d < 0.11d", "true", object.BOOLEAN_OBJ},
+		{"2 > 1.99d", "true", object.BOOLEAN_OBJ},
+		{`{1.50d: "a"}[1.5d]`, "a", object.STRING_OBJ},
+		{`match (2.50d) { 2.5d => "matched", _ => "no" }`, "matched", object.STRING_OBJ},
+		{`match (-2.5d) { -2.50d => "matched", _ => "no" }`, "matched", object.STRING_OBJ},
+		{`decimal("12.5")`, "12.5", object.DECIMAL_OBJ},
+		{`decimal(3, 2)`, "3.00", object.DECIMAL_OBJ},
+		{`decimal("2.675", 2)`, "2.68", object.DECIMAL_OBJ},
+		{`decimal("2.665", 2)`, "2.66", object.DECIMAL_OBJ},
+		{`round(2.5d)`, "2", object.DECIMAL_OBJ},
+		{`round(2.675d, 2, "down")`, "2.67", object.DECIMAL_OBJ},
+		{`round(-2.5d, 0, "half_up")`, "-3", object.DECIMAL_OBJ},
+		{`2.345d.round(1, "ceiling")`, "2.4", object.DECIMAL_OBJ},
+		{`int(12.99d)`, "12", object.INTEGER_OBJ},
+		{`int(-12.99d)`, "-12", object.INTEGER_OBJ},
+		{`int("42")`, "42", object.INTEGER_OBJ},
+		{`int(99999999999999999999.5d)`, "99999999999999999999", object.BIGINT_OBJ},
+		{`format("%s", 1.10d)`, "1.10", object.STRING_OBJ},
+		{`json_stringify({"price": 12.50d})`, `{"price":12.50}`, object.STRING_OBJ},
+		{`json_parse("[1.10, 2]")[0] + 1`, "2.10", object.DECIMAL_OBJ},
+		{"1.5d / 0", "division by zero: 1.5 / 0", object.ERROR_OBJ},
+		{`1.5d + "a"`, "type mismatch: DECIMAL + STRING", object.ERROR_OBJ},
+		{`decimal("1.2.3")`, `decimal: invalid decimal "1.2.3"`, object.ERROR_OBJ},
+		{`round(1.5d, -1)`, "round: scale must be between 0 and 1000, got -1", object.ERROR_OBJ},
+		{`round(1.5d, 0, "sideways")`, `round: unknown rounding mode "sideways"`, object.ERROR_OBJ},
+		{`int("1.5")`, `int: invalid integer "1.5"`, object.ERROR_OBJ},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Type() != tt.typ {
+			t.Errorf("wrong type for %q. want=%s, got=%s (%s)", tt.input, tt.typ, evaluated.Type(), evaluated.Inspect())
+			continue
+		}
+		if evaluated.Type() == object.ERROR_OBJ {
+			testErrorObject(t, evaluated, tt.expected)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong value for %q. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestDecimalContext(t *testing.T) {
+	ctx := object.NewContext(strings.NewReader(""), io.Discard, io.Discard)
+	ctx.DecimalScale = 4
+	ctx.Rounding = object.RoundHalfUp
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1d / 8", "0.1250"},
+		{"2d / 3", "0.6667"},
+		{"1.000005d / 1", "1.000005"},
+		{`decimal("2.665", 2)`, "2.67"},
+		{`round(2.5d)`, "3"},
+		{`round(2.5d, 0, "half_even")`, "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithContext(tt.input, ctx)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong value for %q. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStrictOverflow(t *testing.T) {
+	ctx := object.NewContext(strings.NewReader(""), io.Discard, io.Discard)
+	ctx.StrictOverflow = true
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"9223372036854775807 + 1", errorMessage("integer overflow: 9223372036854775807 + 1")},
+		{"-9223372036854775807 - 2", errorMessage("integer overflow: -9223372036854775807 - 2")},
+		{"4294967296 * 4294967296", errorMessage("integer overflow: 4294967296 * 4294967296")},
+		{"-(-9223372036854775807 - 1)", errorMessage("integer overflow: -(-9223372036854775808)")},
+		{"99999999999999999999", errorMessage("integer overflow: 99999999999999999999")},
+		{`json_parse("[99999999999999999999]")`, errorMessage("integer overflow: 99999999999999999999")},
+		{"9223372036854775807 - 1 + 1", 9223372036854775807},
+		{"-9223372036854775807 - 1", -9223372036854775807 - 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithContext(tt.input, ctx)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+			if err, ok := evaluated.(*object.Error); ok && err.Code != diag.IntegerOverflow {
+				t.Errorf("wrong code for %q. want=%s, got=%s", tt.input, diag.IntegerOverflow, err.Code)
+			}
+		}
+	}
+}
+
+func TestStrictIndexing(t *testing.T) {
+	ctx := object.NewContext(strings.NewReader(""), io.Discard, io.Discard)
+	ctx.StrictIndexing = true
+
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"[1, 2, 3][3]", "index out of range: 3 (length 3)"},
+		{"[1, 2, 3][-4]", "index out of range: -4 (length 3)"},
+		{`"abc"[5]`, "index out of range: 5 (length 3)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithContext(tt.input, ctx)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+
+	testIntegerObject(t, testEval("[1, 2, 3][-1]"), 3)
+}
+
+func TestNull(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		typ      object.ObjectType
+	}{
+		{"null", "null", object.NULL_OBJ},
+		{"null == null", "true", object.BOOLEAN_OBJ},
+		{`{"a": 1}["b"] == null`, "true", object.BOOLEAN_OBJ},
+		{`{"a": 1}["b"] ?? 0`, "0", object.INTEGER_OBJ},
+		{`{"a": 1}["a"] ?? 0`, "1", object.INTEGER_OBJ},
+		{"false ?? 1", "false", object.BOOLEAN_OBJ},
+		{"null ?? null ?? 3", "3", object.INTEGER_OBJ},
+		{"1 ?? missing", "1", object.INTEGER_OBJ},
+		{"let h = null; h?[\"k\"]", "null", object.NULL_OBJ},
+		{`let h = {"a": {"b": 2}}; h?["a"]?["b"]`, "2", object.INTEGER_OBJ},
+		{`let h = {"a": null}; h["a"]?["b"] ?? "none"`, "none", object.STRING_OBJ},
+		{"let xs = null; xs?[1:]", "null", object.NULL_OBJ},
+		{"[1, 2, 3]?[1:]", "[2, 3]", object.ARRAY_OBJ},
+		{"let f = null; f?(missing)", "null", object.NULL_OBJ},
+		{"let f = fn(x) { x * 2 }; f?(2)", "4", object.INTEGER_OBJ},
+		{"let call = fn(g) { g?() }; call(null)", "null", object.NULL_OBJ},
+		{"struct Handler { on }; Handler(null).on?(missing)", "null", object.NULL_OBJ},
+		{"struct Handler { on }; Handler(fn(x) { x + 1 }).on?(1)", "2", object.INTEGER_OBJ},
+		{`match (null) { null => "none", _ => "some" }`, "none", object.STRING_OBJ},
+		{`match (0) { null => "none", _ => "some" }`, "some", object.STRING_OBJ},
+		{"if (null) { 1 } else { 2 }", "2", object.INTEGER_OBJ},
+		{"is_null(null)", "true", object.BOOLEAN_OBJ},
+		{"is_null(first([]))", "true", object.BOOLEAN_OBJ},
+		{"is_null(0)", "false", object.BOOLEAN_OBJ},
+		{"is_null(false)", "false", object.BOOLEAN_OBJ},
+		{`let h = {}; h["k"].is_null()`, "true", object.BOOLEAN_OBJ},
+		{"is_null()", "wrong number of arguments. got=0, want=1", object.ERROR_OBJ},
+		{"let h = null; h[\"k\"]", "index operator not supported: NULL", object.ERROR_OBJ},
+		{"let f = 5; f?()", "not a function: INTEGER", object.ERROR_OBJ},
+		{"null ?? missing", "identifier not found: missing", object.ERROR_OBJ},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Type() != tt.typ {
+			t.Errorf("wrong type for %q. want=%s, got=%s (%s)", tt.input, tt.typ, evaluated.Type(), evaluated.Inspect())
+			continue
+		}
+		if evaluated.Type() == object.ERROR_OBJ {
+			testErrorObject(t, evaluated, tt.expected)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong value for %q. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFunctionArity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"fn(a, b) { a + b }(1, 2)", 3},
+		{"fn(a, b) { a + b }(1)", errorMessage("wrong number of arguments: want=2, got=1")},
+		{"fn(a, b) { a + b }(1, 2, 3)", errorMessage("wrong number of arguments: want=2, got=3")},
+		{"fn() { 1 }(1)", errorMessage("wrong number of arguments: want=0, got=1")},
+		{"fn(a, b = 10) { a + b }(1)", 11},
+		{"fn(a, b = 10) { a + b }(1, 2)", 3},
+		{"fn(a, b = a * 2) { a + b }(3)", 9},
+		{"fn(a, b = 10) { a + b }()", errorMessage("wrong number of arguments: want=1..2, got=0")},
+		{"fn(a, b = 10) { a + b }(1, 2, 3)", errorMessage("wrong number of arguments: want=1..2, got=3")},
+		{"fn(a = c) { a }()", errorMessage("identifier not found: c")},
+		{"fn(a, ...rest) { len(rest) }(1, 2, 3)", 2},
+		{"fn(a, ...rest) { len(rest) }(1)", 0},
+		{"fn(a, ...rest) { rest[1] }(1, 2, 3)", 3},
+		{"fn(a, b = 2, ...rest) { a + b + len(rest) }(1)", 3},
+		{"fn(a, ...rest) { a }()", errorMessage("wrong number of arguments: want=1 or more, got=0")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestStructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"struct Point { x, y }; let p = Point(1, 2); p.x + p.y", 3},
+		{"struct Point { x, y }; let p = Point(1, 2); p.x = 10; p.x", 10},
+		{"struct Point { x, y }; let p = Point(1, 2); p.y = p.x + 5", 6},
+		{"struct Point { x, y }; let p = Point(1, 2); let q = p; q.x = 3; p.x", 3},
+		{"struct Box { v }; let b = Box(Box(4)); b.v.v", 4},
+		{"struct Point { x, y }; Point(1, 2) == Point(1, 2)", true},
+		{"struct Point { x, y }; Point(1, 2) != Point(1, 3)", true},
+		{"struct Point { x, y }; struct Pair { x, y }; Point(1, 2) == Pair(1, 2)", false},
+		{"struct Box { v }; Box(Box(\"a\")) == Box(Box(\"a\"))", true},
+		{"struct Point { x, y }; Point(1, 2) == 1", false},
+		{"struct Point { x, y }; Point(1)", errorMessage("wrong number of arguments: want=2, got=1")},
+		{"struct Point { x, y }; Point(1, 2).z", errorMessage("Point has no field z")},
+		{"struct Point { x, y }; let p = Point(1, 2); p.z = 1", errorMessage("Point has no field z")},
+		{"let a = 5; a.x", errorMessage("cannot access field x of INTEGER")},
+		{"struct Point { x, y }; Point(1, 2) + Point(1, 2)", errorMessage("unknown operator: STRUCT + STRUCT")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+
+	inspect := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, y }; Point", "struct Point { x, y }"},
+		{"struct Point { x, y }; Point(1, \"a\")", "Point{x: 1, y: a}"},
+	}
+
+	for _, tt := range inspect {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMethodCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3].push(4).len()", 4},
+		{"[1, 2, 3].rest().first()", 2},
+		{`"hello".len()`, 5},
+		{`"Hello".upper()`, "HELLO"},
+		{`"Hello".lower()`, "hello"},
+		{`"  hi ".trim()`, "hi"},
+		{`"a,b,c".split(",").reverse().join("-")`, "c-b-a"},
+		{`"hello".contains("ell")`, true},
+		{`"hello".starts_with("he")`, true},
+		{`"hello".ends_with("he")`, false},
+		{`"a-b-c".replace("-", "+")`, "a+b+c"},
+		{"[1, 2, 3].contains(2)", true},
+		{`[1, "a", 3].index_of("a")`, 1},
+		{"[1, 2, 3].index_of(5)", -1},
+		{`{"a": 1, "b": 2}.len()`, 2},
+		{`{"b": 1, "a": 2, 3: 4, true: 5}.keys().join(",")`, "3,true,a,b"},
+		{`{"b": 1, "a": 2}.values().join(",")`, "2,1"},
+		{`{"a": 1}.has("a")`, true},
+		{`{"a": 1}.has("b")`, false},
+		{"struct Counter { step }; let c = Counter(fn(x) { x + 2 }); c.step(1)", 3},
+		{"[1].foo()", errorMessage("unknown method foo for ARRAY")},
+		{`"a".upper(1)`, errorMessage("wrong number of arguments to `upper`. got=1, want=0")},
+		{`"a".split(1)`, errorMessage("argument 1 to `split` must be STRING, got INTEGER")},
+		{"5.len()", errorMessage("argument to `len` not supported, got INTEGER")},
+		{"struct P { x }; P(1).y()", errorMessage("P has no field y")},
+		{`{"a": 1}.has([1])`, errorMessage("unusable as hash key: ARRAY")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"match (2) { 1 => 10, 2 => 20, _ => 30 }", 20},
+		{"match (5) { 1 => 10, _ => 30 }", 30},
+		{"match (5) { 1 => 10 }", nil},
+		{"match (-2) { -2 => 1, _ => 0 }", 1},
+		{`match ("b") { "a" => 1, "b" => 2 }`, 2},
+		{"match (true) { false => 0, true => 1 }", 1},
+		{"match (7) { n => n * 2 }", 14},
+		{"match (7) { n if n < 5 => 0, n if n > 5 => n }", 7},
+		{"match ([]) { [] => 0, [h, ...t] => h }", 0},
+		{"match ([1, 2, 3]) { [h, ...t] => h + len(t) }", 3},
+		{"match ([1, 2]) { [a] => a, [a, b] => a + b, _ => 0 }", 3},
+		{"match ([1, 2, 3]) { [a, b] => 0, [_, ..._] => 1 }", 1},
+		{"match ([1, [2, 3]]) { [a, [b, c]] => a + b + c }", 6},
+		{"match ([1, 2]) { [1, x] => x, _ => 0 }", 2},
+		{`match ({"type": "x", "data": 5}) { {"type": "y"} => 0, {"type": "x", "data": d} => d }`, 5},
+		{`match ({"type": "x"}) { {"data": d} => d, {} => 1 }`, 1},
+		{`match (5) { {} => 1, [] => 2, _ => 3 }`, 3},
+		{"let n = 1; match (5) { n => n }; n", 1},
+		{"let f = fn(xs) { match (xs) { [] => 0, [h, ...t] => h + f(t) } }; f([1, 2, 3, 4])", 10},
+		{"let f = fn(x) { match (x) { n => fn() { n } } }; f(4)()", 4},
+		{"match (1) { n if n.foo() => 1 }", errorMessage("unknown method foo for INTEGER")},
+		{"match (y) { _ => 1 }", errorMessage("identifier not found: y")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a + b", 3},
+		{"let [a, ...rest] = [1, 2, 3]; a + len(rest)", 3},
+		{"let [a, ...rest] = [1]; len(rest)", 0},
+		{"let [_, [x, y]] = [1, [2, 3]]; x * y", 6},
+		{`let {name, age} = {"name": "ann", "age": 30}; age`, 30},
+		{`let {"data": [d, ..._]} = {"data": [4, 5]}; d`, 4},
+		{"struct Person { name, age }; let {age} = Person(\"bo\", 7); age", 7},
+		{"let f = fn([a, b]) { a - b }; f([5, 2])", 3},
+		{`let f = fn({x, y}, z) { x + y + z }; f({"x": 1, "y": 2}, 3)`, 6},
+		{"let f = fn([a, b] = [1, 2]) { a + b }; f()", 3},
+		{"let [a, b] = [1, 2, 3];", errorMessage("cannot destructure array of 3 elements into [a, b]")},
+		{"let [a, b, c, ...d] = [1, 2];", errorMessage("cannot destructure array of 2 elements into [a, b, c, ...d]")},
+		{"let [a] = 5;", errorMessage("cannot destructure INTEGER as array")},
+		{`let {age} = {"name": 1};`, errorMessage(`hash has no key "age"`)},
+		{"let {age} = [1];", errorMessage("cannot destructure ARRAY as hash")},
+		{"struct P { x }; let {y} = P(1);", errorMessage("P has no field y")},
+		{"let [1, a] = [2, 3];", errorMessage("2 does not match 1")},
+		{"let f = fn([a, b]) { a }; f(1)", errorMessage("cannot destructure INTEGER as array")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = 0; for (x in [1, 2, 3]) { let sum = sum + x }; sum", 6},
+		{"let sum = 0; for (i, x in [5, 6]) { let sum = sum + i }; sum", 1},
+		{`let out = ""; for (k, v in {"b": 2, "a": 1, 3: "c"}) { let out = out + format("%v=%v ", k, v) }; out`, "3=c a=1 b=2 "},
+		{`let out = ""; for (k in {"b": 2, "a": 1}) { let out = out + k }; out`, "ab"},
+		{`let out = ""; for (c in "héllo") { let out = c + out }; out`, "olléh"},
+		{`let out = ""; for (i, c in "héllo") { let out = out + format("%d", i) }; out`, "01345"},
+		{"let sum = 0; for (x in range(5)) { let sum = sum + x }; sum", 10},
+		{"let sum = 0; for (x in range(2, 5)) { let sum = sum + x }; sum", 9},
+		{"let sum = 0; for (x in range(10, 0, -3)) { let sum = sum + x }; sum", 22},
+		{"let sum = 0; for (x in range(5, 0)) { let sum = sum + x }; sum", 0},
+		{"let sum = 0; for (x in range(10)) { if (x == 4) { break } let sum = sum + x }; sum", 6},
+		{"let sum = 0; for (x in range(5)) { if (x == 2) { continue; } let sum = sum + x }; sum", 8},
+		{"let n = 0; for (a in range(3)) { for (b in range(3)) { if (b == 1) { break } let n = n + 1 } }; n", 3},
+		{"let f = fn() { for (x in range(100)) { if (x == 7) { return x } } }; f()", 7},
+		{"let xs = [1, 2]; for (x in xs) { let xs = push(xs, x) }; len(xs)", 4},
+		{"let last = 0; for (x in [1, 2, 3]) { let last = x }; x", 3},
+		{"len(range(0, 10, 3))", 4},
+		{"len(range(10, 0, -4))", 3},
+		{"len(range(-9223372036854775807, 9223372036854775807))", "18446744073709551614"},
+		{"range(-9223372036854775807 - 1, 9223372036854775807).len()", "18446744073709551615"},
+		{"len(range(9223372036854775806, 9223372036854775807))", 1},
+		{"range(5)", "range(0, 5, 1)"},
+		{"for (x in 5) {}", errorMessage("cannot iterate over INTEGER")},
+		{"for (x in [1]) { x + true }", errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{"range(1, 2, 0)", errorMessage("`range` step must not be 0")},
+		{`range("a")`, errorMessage("argument 1 to `range` must be INTEGER, got STRING")},
+		{"range()", errorMessage("wrong number of arguments. got=0, want=1..3")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q. got=%q, want=%q", tt.input, evaluated.Inspect(), expected)
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let count = fn(n) { for (i in range(n)) { yield i * 2 } }; let sum = 0; for (x in count(4)) { let sum = sum + x }; sum", 12},
+		{"let g = fn() { yield 1; yield 2; }; g().collect()", "[1, 2]"},
+		{"let g = fn() { yield 1; yield 2; }; let it = g(); it.next(); it.next()", `{done: false, value: 2}`},
+		{"let g = fn() { yield 1 }; let it = g(); it.next(); it.next()", `{done: true, value: null}`},
+		{"let g = fn() { yield 1; return 5; yield 2 }; g().collect()", "[1]"},
+		{"let g = fn(a, b) { yield a; yield b }; let n = 0; for (i, x in g(5, 6)) { let n = n + i * x }; n", 6},
+		// an endless generator is only run as far as it's iterated
+		{"let nat = fn() { let n = 0; for (_ in range(1000000000)) { yield n; let n = n + 1 } }; let last = 0; for (n in nat()) { if (n == 10) { break } let last = n }; last", 9},
+		{"let nat = fn() { let n = 0; for (_ in range(1000000000)) { yield n; let n = n + 1 } }; let f = fn() { for (n in nat()) { if (n == 3) { return n } } }; f()", 3},
+		{"let inner = fn() { yield 1; yield 2 }; let outer = fn() { for (x in inner()) { yield x * 10 } }; outer().collect()", "[10, 20]"},
+		{"let g = fn() { yield 1 }; let it = g(); it.collect(); it.collect()", "[]"},
+		{"[1, 2, 3].iter().collect()", "[1, 2, 3]"},
+		{`iter({"b": 1, "a": 2}).collect()`, "[2, 1]"},
+		{`let it = iter("ab"); it.next(); it.next()`, `{done: false, value: b}`},
+		{"let it = iter(range(3)); it.next(); it.collect()", "[1, 2]"},
+		{"let s = 0; for (x in iter([1, 2])) { let s = s + x }; s", 3},
+		{"let g = fn() { yield 1; yield 1 + true }; g().collect()", errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{"let g = fn() { yield 1; yield 1 + true }; for (x in g()) { x }", errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{"let g = fn(a) { yield a }; g()", errorMessage("wrong number of arguments: want=1, got=0")},
+		{"iter(5)", errorMessage("argument to `iter` not supported, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q. got=%q, want=%q", tt.input, evaluated.Inspect(), expected)
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let t = spawn(fn(a, b) { a + b }, 1, 2); await(t)", 3},
+		{"let t = spawn(fn() { 5 }); t.await() + t.await()", 10},
+		{"let tasks = [spawn(fn() { 1 }), spawn(fn() { 2 }), spawn(fn() { 3 })]; let sum = 0; for (t in tasks) { let sum = sum + t.await() }; sum", 6},
+		{"let t = spawn(fn() { 1 + true }); await(t)", errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{"let ch = channel(); spawn(fn() { ch.send(1); ch.send(2); ch.close() }); recv(ch) + ch.recv()", 3},
+		{"let ch = channel(1); send(ch, 4); recv(ch)", 4},
+		{"let ch = channel(); spawn(fn() { for (i in range(4)) { ch.send(i) } ch.close() }); let sum = 0; for (x in ch) { let sum = sum + x }; sum", 6},
+		{"let ch = channel(1); close(ch); recv(ch)", "null"},
+		{"let ch = channel(1); close(ch); send(ch, 1)", errorMessage("send on closed channel")},
+		{"let ch = channel(1); close(ch); close(ch)", errorMessage("close of closed channel")},
+		// closing wakes a sender blocked on the channel
+		{"let ready = channel(1); let c = channel(0); let t = spawn(fn() { send(ready, 1); send(c, 1) }); recv(ready); close(c); await(t)", errorMessage("send on closed channel")},
+		{"channel(-1)", errorMessage("channel capacity must not be negative, got -1")},
+		{"spawn(5)", errorMessage("argument to `spawn` must be a function, got INTEGER")},
+		{"await(5)", errorMessage("argument to `await` must be TASK, got INTEGER")},
+		{"recv(5)", errorMessage("argument 1 to `recv` must be CHANNEL, got INTEGER")},
+		// a counter shared by the closures of several tasks
+		{"let results = channel(10); let work = fn(n) { results.send(n * n) }; let ts = [spawn(work, 1), spawn(work, 2), spawn(work, 3)]; for (t in ts) { t.await() }; results.close(); let sum = 0; for (r in results) { let sum = sum + r }; sum", 14},
+
+		// select
+		{`let a = channel(1); let b = channel(1); send(b, "b"); select { x = recv(a) => x, y = recv(b) => "got " + y }`, "got b"},
+		{`let a = channel(); select { recv(a) => "a", _ => "default" }`, "default"},
+		{`let a = channel(1); select { send(a, 7) => recv(a), _ => 0 }`, 7},
+		{`let a = channel(1); select { a.send(8) => a.recv() }`, 8},
+		{`let a = channel(); close(a); select { v = a.recv() => v }`, "null"},
+		{`let a = channel(); spawn(fn() { a.send(9) }); select { v = recv(a) => v }`, 9},
+		{`let a = channel(1); select { v = recv(a) => v, _ => 1 }; v`, errorMessage("identifier not found: v")},
+		{`select { recv(5) => 1 }`, errorMessage("cannot recv on INTEGER, not a channel")},
+		{`let a = channel(1); close(a); select { send(a, 1) => 1 }`, errorMessage("send on closed channel")},
+		{`let a = channel(1); select { send(a, 1) => close(a) }; recv(a)`, 1},
+		{`let ready = channel(1); let c = channel(); let t = spawn(fn() { send(ready, 1); select { send(c, 1) => 1 } }); recv(ready); close(c); await(t)`, errorMessage("send on closed channel")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q. got=%q, want=%q", tt.input, evaluated.Inspect(), expected)
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+// tasks reading the environment of their closure while it's written to
+func TestConcurrentEnvironment(t *testing.T) {
+	input := `
+let shared = 0;
+let bump = fn(i) { let local = shared; for (_ in range(50)) { let x = shared + i; } i };
+let tasks = [];
+for (i in range(20)) { let shared = i; let tasks = push(tasks, spawn(bump, i)) }
+let sum = 0;
+for (t in tasks) { let sum = sum + t.await() }
+sum`
+	testIntegerObject(t, testEval(input), 190)
+}
+
+// interpreters with contexts of their own don't see each other's output,
+// settings or builtins. run with -race
+func TestConcurrentStruct(t *testing.T) {
+	input := `
+struct Counter { n }
+let c = Counter(0);
+let bump = fn(i) { for (j in range(100)) { c.n = i + j; let seen = c.n; let same = c == Counter(seen); puts(c) } i };
+let tasks = [];
+for (i in range(8)) { let tasks = push(tasks, spawn(bump, i)) }
+let sum = 0;
+for (t in tasks) { let sum = sum + t.await() }
+sum + c.n - c.n`
+	testIntegerObject(t, testEval(input), 28)
+}
+
+func TestConcurrentGenerator(t *testing.T) {
+	input := `
+let numbers = fn() { for (i in range(1000)) { yield i } };
+let it = numbers();
+let drain = fn() { let sum = 0; for (x in it) { let sum = sum + x } sum };
+let tasks = [];
+for (i in range(4)) { let tasks = push(tasks, spawn(drain)) }
+let sum = 0;
+for (t in tasks) { let sum = sum + t.await() }
+sum`
+	testIntegerObject(t, testEval(input), 499500)
+}
+
+func TestConcurrentInterpreters(t *testing.T) {
+	input := `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let total = 0;
+for (i in range(%d)) { let total = total + fib(i) }
+puts(name(), total);`
+
+	const interpreters = 16
+	outputs := make([]bytes.Buffer, interpreters)
+	results := make([]object.Object, interpreters)
+	done := make(chan struct{})
+
+	for i := 0; i < interpreters; i++ {
+		i := i
+		ctx := object.NewContext(strings.NewReader(""), &outputs[i], io.Discard)
+		ctx.StrictIndexing = i%2 == 0
+		ctx.Builtins = Builtins()
+		ctx.Builtins["name"] = &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				return &object.Integer{Value: int64(i)}
+			},
+		}
+
+		go func() {
+			defer func() { done <- struct{}{} }()
+			program := fmt.Sprintf(input, i)
+			testEvalWithContext(program, ctx)
+			results[i] = testEvalWithContext(program+" [1][5]", ctx)
+		}()
+	}
+	for i := 0; i < interpreters; i++ {
+		<-done
+	}
+
+	fib := []int64{0, 1}
+	for len(fib) < interpreters {
+		fib = append(fib, fib[len(fib)-1]+fib[len(fib)-2])
+	}
+	for i := 0; i < interpreters; i++ {
+		total := int64(0)
+		for _, n := range fib[:i] {
+			total += n
+		}
+		want := strings.Repeat(fmt.Sprintf("%d\n%d\n", i, total), 2)
+		if outputs[i].String() != want {
+			t.Errorf("interpreter %d: wrong output. want=%q, got=%q", i, want, outputs[i].String())
+		}
+		if i%2 == 0 {
+			testErrorObject(t, results[i], "index out of range: 5 (length 1)")
+		} else {
+			testNullObject(t, results[i])
+		}
+	}
+	// the default registry is left alone
+	if _, ok := builtins["name"]; ok {
+		t.Errorf("builtin added to a context leaked into the defaults")
+	}
+}
+
+func TestHashInspectOrder(t *testing.T) {
+	evaluated := testEval(`{"b": 1, 2: 2, "a": 3, false: 4, 1: 5}`)
+	expected := "{1: 5, 2: 2, false: 4, a: 3, b: 1}"
+	if evaluated.Inspect() != expected {
+		t.Errorf("wrong inspect. got=%q, want=%q", evaluated.Inspect(), expected)
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3, 4][1:3]", []int64{2, 3}},
+		{"[1, 2, 3, 4][2:]", []int64{3, 4}},
+		{"[1, 2, 3, 4][:2]", []int64{1, 2}},
+		{"[1, 2, 3, 4][:]", []int64{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][-2:]", []int64{3, 4}},
+		{"[1, 2, 3, 4][:-1]", []int64{1, 2, 3}},
+		{"[1, 2, 3, 4][3:1]", []int64{}},
+		{"[1, 2, 3, 4][-10:10]", []int64{1, 2, 3, 4}},
+		{`"hello"[1:3]`, "el"},
+		{`"hello"[-3:]`, "llo"},
+		{`"hello"[:0]`, ""},
+		{`[1, 2]["a":]`, "slice index must be INTEGER, got STRING"},
+		{`5[1:2]`, "slice operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong number of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, el := range expected {
+				testIntegerObject(t, array.Elements[i], el)
+			}
+		case string:
+			switch result := evaluated.(type) {
+			case *object.String:
+				if result.Value != expected {
+					t.Errorf("String has wrong value. want=%q, got=%q", expected, result.Value)
+				}
+			case *object.Error:
+				if result.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, result.Message)
+				}
+			default:
+				t.Errorf("object is not String or Error. got=%T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
 	{
@@ -472,6 +1345,10 @@ if (10 > 1) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			"let x = ;",
+			`invalid syntax near ";"`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -487,3 +1364,249 @@ if (10 > 1) {
 		}
 	}
 }
+
+func TestJSONBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`json_parse("42")`, 42},
+		{`json_parse("[1, 2, 3]")[2]`, 3},
+		{`json_parse(json_stringify({"a": {"b": [10, 20]}}))["a"]["b"][1]`, 20},
+		{`json_parse("null")`, nil},
+		{`len(json_parse(json_stringify("monkey")))`, 6},
+		{`json_parse("true") == true`, true},
+		{`json_parse(json_stringify({"a": [1, 2]}))["a"][1]`, 2},
+		{`json_parse("1e3")`, "json_parse: unsupported number 1e3"},
+		{`json_parse("[1, ")`, "json_parse: unexpected EOF"},
+		{`json_parse("1 2")`, "json_parse: unexpected data after top-level value"},
+		{`json_stringify(fn(x) { x })`, "json_stringify: unsupported type FUNCTION"},
+		{`json_stringify({1: 2})`, "json_stringify: hash key must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestJSONStringify(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json_stringify([1, "two", true, {"k": [json_parse("null")]}])`, `[1,"two",true,{"k":[null]}]`},
+		{`json_stringify({"b": 1, "a": 2})`, `{"a":2,"b":1}`},
+		{`json_stringify("<a&b>")`, `"<a&b>"`},
+		{`json_stringify([1], 2)`, "[\n  1\n]"},
+		{`json_stringify({"a": [1]}, "--")`, "{\n--\"a\": [\n----1\n--]\n}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. want=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+}
+
+func TestFileBuiltins(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Skipf("symlinks not supported: %s", err)
+	}
+
+	ctx := object.NewContext(strings.NewReader(""), io.Discard, io.Discard)
+	ctx.FileRoot = root
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`write_file("notes.txt", "hello")`, nil},
+		{`append_file("notes.txt", " world")`, nil},
+		{`read_file("notes.txt")`, "hello world"},
+		{`exists("notes.txt")`, true},
+		{`exists("missing.txt")`, false},
+		{`list_dir()`, []string{"escape", "notes.txt"}},
+		{`list_dir(".")[1]`, "notes.txt"},
+		{`read_file("missing.txt")`, errorMessage("read_file: missing.txt: no such file or directory")},
+		{`read_file("../secret.txt")`, errorMessage(`read_file: path escapes the file root: "../secret.txt"`)},
+		{`read_file("sub/../../secret.txt")`, errorMessage(`read_file: path escapes the file root: "sub/../../secret.txt"`)},
+		{`read_file("escape/secret.txt")`, errorMessage(`read_file: path escapes the file root: "escape/secret.txt"`)},
+		{`write_file("escape/new.txt", "x")`, errorMessage(`write_file: path escapes the file root: "escape/new.txt"`)},
+		{`read_file("/etc/passwd")`, errorMessage(`read_file: path must be relative to the file root, got "/etc/passwd"`)},
+		{`write_file("notes.txt", 1)`, errorMessage("second argument to `write_file` must be STRING, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithContext(tt.input, ctx)
+
+		switch expected := tt.expected.(type) {
+		case nil:
+			testNullObject(t, evaluated)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case []string:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong number of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, name := range expected {
+				testStringObject(t, array.Elements[i], name)
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); err == nil {
+		t.Errorf("write_file created a file outside of the root")
+	}
+}
+
+func TestFileBuiltinsDisabled(t *testing.T) {
+	testErrorObject(t, testEval(`read_file("notes.txt")`), "read_file: file access is disabled")
+	testErrorObject(t, testEval(`exists("notes.txt")`), "exists: file access is disabled")
+}
+
+type errorMessage string
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("String has wrong value. want=%q, got=%q", expected, result.Value)
+		return false
+	}
+	return true
+}
+
+func testErrorObject(t *testing.T, obj object.Object, expected string) bool {
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+		return false
+	}
+	return true
+}
+
+func TestOutputBuiltins(t *testing.T) {
+	tests := []struct {
+		input          string
+		stdin          string
+		expected       interface{}
+		expectedStdout string
+		expectedStderr string
+	}{
+		{`puts("a", 1)`, "", nil, "a\n1\n", ""},
+		{`print("a", 1); print([true])`, "", nil, "a1[true]", ""},
+		{`eprint("oops")`, "", nil, "", "oops"},
+		{`format("%d-%s-%t-%v", 7, "x", true, [1])`, "", "7-x-true-[1]", "", ""},
+		{`format("%05d|%q", 42, "hi")`, "", "00042|\"hi\"", "", ""},
+		{`input("name? ")`, "monkey\nrest\n", "monkey", "name? ", ""},
+		{`input() + input()`, "a\r\nb", "ab", "", ""},
+		{`input()`, "", nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		ctx := object.NewContext(strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		evaluated := Eval(p.ParseProgram(), object.NewEnvironmentWithContext(ctx))
+
+		switch expected := tt.expected.(type) {
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			testStringObject(t, evaluated, expected)
+		}
+
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("wrong stdout for %q. want=%q, got=%q", tt.input, tt.expectedStdout, stdout.String())
+		}
+		if stderr.String() != tt.expectedStderr {
+			t.Errorf("wrong stderr for %q. want=%q, got=%q", tt.input, tt.expectedStderr, stderr.String())
+		}
+	}
+}
+
+func TestErrorDiagnostics(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedCode string
+		expectedPos  string
+	}{
+		{"let a = 1;\nlet b = a + true;", diag.TypeMismatch, "2:11"},
+		{"-true", diag.UnknownOperator, "1:1"},
+		{"let f = fn() { missing };\nf()", diag.UndefinedName, "1:16"},
+		{"5(1)", diag.NotCallable, "1:2"},
+		{`len(1, 2)`, diag.WrongArgumentCount, "1:4"},
+		{`{"a": 1}[[1]]`, diag.UnhashableKey, "1:9"},
+		{"let f = fn() { g(1) };\nlet g = fn() { 1 };\nf()", diag.WrongArgumentCount, "1:17"},
+		{"let f = fn() { return 5(1) };\nf()", diag.NotCallable, "1:24"},
+		{"let f = fn() { len(1, 2) };\nf()", diag.WrongArgumentCount, "1:19"},
+		{"let x = 0;\n1 / x", diag.DivisionByZero, "2:3"},
+		{"99999999999999999999 / 0", diag.DivisionByZero, "1:22"},
+		{"1.5d / 0", diag.DivisionByZero, "1:6"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		d := errObj.Diagnostic()
+		if d.Code != tt.expectedCode {
+			t.Errorf("wrong code for %q. want=%s, got=%s", tt.input, tt.expectedCode, d.Code)
+		}
+		if d.Span.Start.String() != tt.expectedPos {
+			t.Errorf("wrong position for %q. want=%s, got=%s", tt.input, tt.expectedPos, d.Span.Start)
+		}
+	}
+}
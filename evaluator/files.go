@@ -0,0 +1,193 @@
+package evaluator
+
+import (
+	"errors"
+	"intInGo/diag"
+	"intInGo/object"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolve a script-supplied path to a host path inside the context's
+// FileRoot, rejecting absolute paths and anything that escapes the root
+// via .. or symlinks. file I/O is disabled while there is no root
+func resolvePath(ctx *object.Context, name string, path string) (string, *object.Error) {
+	if ctx.FileRoot == "" {
+		return "", newError("%s: file access is disabled", name)
+	}
+	if filepath.IsAbs(path) {
+		return "", newError("%s: path must be relative to the file root, got %q", name, path)
+	}
+
+	root, err := filepath.Abs(ctx.FileRoot)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return "", newError("%s: invalid file root: %s", name, err)
+	}
+
+	full := filepath.Join(root, path)
+	if !within(root, full) {
+		return "", newError("%s: path escapes the file root: %q", name, path)
+	}
+
+	// follow symlinks on the longest existing prefix of the path, so that
+	// files which don't exist yet can still be created
+	existing := full
+	rest := ""
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+
+	real, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", newError("%s: %s", name, describe(err))
+	}
+	if !within(root, real) {
+		return "", newError("%s: path escapes the file root: %q", name, path)
+	}
+
+	return filepath.Join(real, rest), nil
+}
+
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// strip the host path from os errors so the root isn't leaked to scripts
+func describe(err error) string {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err.Error()
+	}
+	return err.Error()
+}
+
+func readFile(ctx *object.Context, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+	path, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to `read_file` must be STRING, got %s", args[0].Type())
+	}
+
+	full, errObj := resolvePath(ctx, "read_file", path.Value)
+	if errObj != nil {
+		return errObj
+	}
+
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return newError("read_file: %s: %s", path.Value, describe(err))
+	}
+
+	return &object.String{Value: string(data)}
+}
+
+func writeFile(ctx *object.Context, args ...object.Object) object.Object {
+	return writeToFile(ctx, "write_file", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, args)
+}
+
+func appendFile(ctx *object.Context, args ...object.Object) object.Object {
+	return writeToFile(ctx, "append_file", os.O_CREATE|os.O_WRONLY|os.O_APPEND, args)
+}
+
+func writeToFile(ctx *object.Context, name string, flag int, args []object.Object) object.Object {
+	if len(args) != 2 {
+		return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+	path, ok := args[0].(*object.String)
+	if !ok {
+		return newError("first argument to `%s` must be STRING, got %s", name, args[0].Type())
+	}
+	content, ok := args[1].(*object.String)
+	if !ok {
+		return newError("second argument to `%s` must be STRING, got %s", name, args[1].Type())
+	}
+
+	full, errObj := resolvePath(ctx, name, path.Value)
+	if errObj != nil {
+		return errObj
+	}
+
+	f, err := os.OpenFile(full, flag, 0644)
+	if err != nil {
+		return newError("%s: %s: %s", name, path.Value, describe(err))
+	}
+	_, err = f.WriteString(content.Value)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return newError("%s: %s: %s", name, path.Value, describe(err))
+	}
+
+	return NULL
+}
+
+func listDir(ctx *object.Context, args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+
+	path := "."
+	if len(args) == 1 {
+		str, ok := args[0].(*object.String)
+		if !ok {
+			return newError("argument to `list_dir` must be STRING, got %s", args[0].Type())
+		}
+		path = str.Value
+	}
+
+	full, errObj := resolvePath(ctx, "list_dir", path)
+	if errObj != nil {
+		return errObj
+	}
+
+	entries, err := os.ReadDir(full)
+	if err != nil {
+		return newError("list_dir: %s: %s", path, describe(err))
+	}
+
+	// entries are sorted by file name
+	names := make([]object.Object, len(entries))
+	for i, entry := range entries {
+		names[i] = &object.String{Value: entry.Name()}
+	}
+
+	return &object.Array{Elements: names}
+}
+
+func exists(ctx *object.Context, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+	path, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to `exists` must be STRING, got %s", args[0].Type())
+	}
+
+	full, errObj := resolvePath(ctx, "exists", path.Value)
+	if errObj != nil {
+		return errObj
+	}
+
+	_, err := os.Stat(full)
+	return nativeBoolToBooleanObject(err == nil)
+}
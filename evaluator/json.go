@@ -0,0 +1,172 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"intInGo/diag"
+	"intInGo/object"
+	"math/big"
+	"strings"
+)
+
+// decode a JSON document into monkey objects
+func jsonParse(ctx *object.Context, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to `json_parse` must be STRING, got %s", args[0].Type())
+	}
+
+	dec := json.NewDecoder(strings.NewReader(str.Value))
+	dec.UseNumber()
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return newError("json_parse: %s", err)
+	}
+	if dec.More() {
+		return newError("json_parse: unexpected data after top-level value")
+	}
+
+	return jsonToObject(value, ctx)
+}
+
+func jsonToObject(value interface{}, ctx *object.Context) object.Object {
+	switch value := value.(type) {
+	case nil:
+		return NULL
+	case bool:
+		return nativeBoolToBooleanObject(value)
+	case string:
+		return &object.String{Value: value}
+	case json.Number:
+		if integer, ok := new(big.Int).SetString(value.String(), 10); ok {
+			return bigResult(integer, ctx, value.String())
+		}
+		// numbers with a fraction are decimals, so they keep their digits
+		if d, ok := object.ParseDecimal(value.String()); ok {
+			return d
+		}
+		return newError("json_parse: unsupported number %s", value)
+	case []interface{}:
+		elements := make([]object.Object, len(value))
+		for i, el := range value {
+			elements[i] = jsonToObject(el, ctx)
+			if isError(elements[i]) {
+				return elements[i]
+			}
+		}
+		return &object.Array{Elements: elements}
+	case map[string]interface{}:
+		pairs := make(map[object.HashKey]object.HashPair, len(value))
+		for k, v := range value {
+			key := &object.String{Value: k}
+			val := jsonToObject(v, ctx)
+			if isError(val) {
+				return val
+			}
+			pairs[key.HashKey()] = object.HashPair{Key: key, Value: val}
+		}
+		return &object.Hash{Pairs: pairs}
+	default:
+		return newError("json_parse: unsupported value %v", value)
+	}
+}
+
+// encode a monkey object as JSON, optionally indented by a number of spaces
+// or a given indent string
+func jsonStringify(ctx *object.Context, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newCodedError(diag.WrongArgumentCount, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	indent := ""
+	if len(args) == 2 {
+		switch arg := args[1].(type) {
+		case *object.Integer:
+			if arg.Value < 0 {
+				return newError("json_stringify: indent must not be negative, got %d", arg.Value)
+			}
+			indent = strings.Repeat(" ", int(arg.Value))
+		case *object.String:
+			indent = arg.Value
+		default:
+			return newError("indent argument to `json_stringify` must be INTEGER or STRING, got %s", args[1].Type())
+		}
+	}
+
+	value, errObj := objectToJSON(args[0], map[object.Object]bool{})
+	if errObj != nil {
+		return errObj
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(value); err != nil {
+		return newError("json_stringify: %s", err)
+	}
+
+	return &object.String{Value: strings.TrimSuffix(out.String(), "\n")}
+}
+
+// convert an object to values encoding/json understands, tracking the arrays
+// and hashes currently being visited to detect cycles
+func objectToJSON(obj object.Object, visiting map[object.Object]bool) (interface{}, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return nil, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.BigInt:
+		return json.Number(obj.Value.String()), nil
+	case *object.Decimal:
+		return json.Number(obj.Inspect()), nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Array:
+		if visiting[obj] {
+			return nil, newError("json_stringify: cycle detected in ARRAY")
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		elements := make([]interface{}, len(obj.Elements))
+		for i, el := range obj.Elements {
+			value, err := objectToJSON(el, visiting)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *object.Hash:
+		if visiting[obj] {
+			return nil, newError("json_stringify: cycle detected in HASH")
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		// encoding/json sorts map keys, which keeps the output deterministic
+		pairs := make(map[string]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return nil, newError("json_stringify: hash key must be STRING, got %s", pair.Key.Type())
+			}
+			value, err := objectToJSON(pair.Value, visiting)
+			if err != nil {
+				return nil, err
+			}
+			pairs[key.Value] = value
+		}
+		return pairs, nil
+	default:
+		return nil, newError("json_stringify: unsupported type %s", obj.Type())
+	}
+}
@@ -0,0 +1,94 @@
+package evaluator
+
+import (
+	"intInGo/ast"
+	"intInGo/diag"
+	"intInGo/object"
+)
+
+// run the body of a for loop once per element of the iterable. like an if
+// block the body shares the environment of the loop, so the loop variables
+// and anything it lets stay visible after it
+func evalForStatement(
+	node *ast.ForStatement,
+	env *object.Environment,
+) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	it, ok := iterable.(object.Iterable)
+	if !ok {
+		return newCodedError(diag.NotIterable, "cannot iterate over %s", iterable.Type())
+	}
+	iter := it.Iter()
+	if stopper, ok := iter.(object.Stopper); ok {
+		defer stopper.Stop()
+	}
+
+	// with a single name a hash is iterated by key
+	_, keysOnly := iterable.(*object.Hash)
+	keysOnly = keysOnly && node.Key == nil
+
+	for {
+		key, value, ok := iter.Next()
+		if !ok {
+			return nil
+		}
+		if isError(value) {
+			return value
+		}
+		if keysOnly {
+			value = key
+		}
+
+		if node.Key != nil {
+			env.Set(node.Key.Value, key)
+		}
+		env.Set(node.Value.Value, value)
+
+		res := Eval(node.Body, env)
+		if res == nil {
+			continue
+		}
+		switch res.Type() {
+		case object.BREAK_OBJ:
+			return nil
+		case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+			return res
+		}
+	}
+}
+
+// create the generator of a call to a generator function, its body only
+// starts running once the first value is asked for
+func newGenerator(fn *object.Function, env *object.Environment) *object.Generator {
+	return &object.Generator{
+		Run: func(yield func(object.Object) bool) object.Object {
+			env.SetYield(yield)
+			return trampoline(unwrapReturnValue(Eval(fn.Body, env)), env.Context())
+		},
+	}
+}
+
+func evalYieldExpression(
+	node *ast.YieldExpression,
+	env *object.Environment,
+) object.Object {
+	value := Eval(node.Value, env)
+	if isError(value) {
+		return value
+	}
+
+	yield := env.Yield()
+	if yield == nil {
+		return newError("yield outside of a generator")
+	}
+	// unwind the body like an error would once the generator is stopped,
+	// nobody sees what it returns then
+	if !yield(value) {
+		return newError("generator stopped")
+	}
+	return NULL
+}
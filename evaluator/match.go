@@ -0,0 +1,161 @@
+package evaluator
+
+import (
+	"fmt"
+	"intInGo/ast"
+	"intInGo/diag"
+	"intInGo/object"
+)
+
+// evaluate the body of the first arm whose pattern matches the subject
+// and whose guard holds. each arm binds its names in an environment of its
+// own, null when no arm matches like an if without else
+func evalMatchExpression(
+	me *ast.MatchExpression,
+	env *object.Environment,
+	tail bool,
+) object.Object {
+	subject := Eval(me.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range me.Arms {
+		armEnv := object.NewEnclosedEnvironment(env)
+		mismatch, err := destructure(arm.Pattern, subject, armEnv)
+		if err != nil {
+			return err
+		}
+		if mismatch != "" {
+			continue
+		}
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+		return evalBranch(arm.Body, armEnv, tail)
+	}
+
+	return NULL
+}
+
+// bind the names of pattern to the parts of value in env, or a pattern
+// mismatch error when value has another shape
+func bindPattern(
+	pattern ast.Pattern,
+	value object.Object,
+	env *object.Environment,
+) object.Object {
+	mismatch, err := destructure(pattern, value, env)
+	if err != nil {
+		return err
+	}
+	if mismatch != "" {
+		return newCodedError(diag.PatternMismatch, "%s", mismatch)
+	}
+	return nil
+}
+
+// match value against pattern, binding its names in env. the mismatch
+// says why the value doesn't have the shape of the pattern, it's empty
+// when it does
+func destructure(
+	pattern ast.Pattern,
+	value object.Object,
+	env *object.Environment,
+) (string, object.Object) {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return "", nil
+
+	case *ast.BindingPattern:
+		env.Set(pattern.Name.Value, value)
+		return "", nil
+
+	case *ast.LiteralPattern:
+		literal := Eval(pattern.Value, env)
+		if isError(literal) {
+			return "", literal
+		}
+		if !objectsEqual(literal, value) {
+			return fmt.Sprintf("%s does not match %s", value.Inspect(), pattern), nil
+		}
+		return "", nil
+
+	case *ast.ArrayPattern:
+		arr, ok := value.(*object.Array)
+		if !ok {
+			return fmt.Sprintf("cannot destructure %s as array", value.Type()), nil
+		}
+		n := len(pattern.Elements)
+		if len(arr.Elements) < n || (pattern.Rest == nil && len(arr.Elements) != n) {
+			return fmt.Sprintf("cannot destructure array of %d elements into %s", len(arr.Elements), pattern), nil
+		}
+		for i, el := range pattern.Elements {
+			if mismatch, err := destructure(el, arr.Elements[i], env); mismatch != "" || err != nil {
+				return mismatch, err
+			}
+		}
+		if pattern.Rest != nil && pattern.Rest.Value != "_" {
+			rest := make([]object.Object, len(arr.Elements)-n)
+			copy(rest, arr.Elements[n:])
+			env.Set(pattern.Rest.Value, &object.Array{Elements: rest})
+		}
+		return "", nil
+
+	case *ast.HashPattern:
+		switch value.(type) {
+		case *object.Hash, *object.Struct:
+		default:
+			return fmt.Sprintf("cannot destructure %s as hash", value.Type()), nil
+		}
+		for i, key := range pattern.Keys {
+			k := Eval(key, env)
+			if isError(k) {
+				return "", k
+			}
+			v, mismatch := lookupKey(value, k)
+			if mismatch != "" {
+				return mismatch, nil
+			}
+			if mismatch, err := destructure(pattern.Values[i], v, env); mismatch != "" || err != nil {
+				return mismatch, err
+			}
+		}
+		return "", nil
+	}
+
+	return "", nil
+}
+
+// value of a hash key or, for string keys, of a struct field
+func lookupKey(value object.Object, key object.Object) (object.Object, string) {
+	switch value := value.(type) {
+	case *object.Hash:
+		pair, ok := value.Pairs[key.(object.Hashable).HashKey()]
+		if !ok {
+			return nil, fmt.Sprintf("hash has no key %s", describeKey(key))
+		}
+		return pair.Value, ""
+	case *object.Struct:
+		name, ok := key.(*object.String)
+		if !ok || !value.Def.HasField(name.Value) {
+			return nil, fmt.Sprintf("%s has no field %s", value.Def.Name, key.Inspect())
+		}
+		return value.Get(name.Value), ""
+	}
+	return nil, ""
+}
+
+func describeKey(key object.Object) string {
+	if s, ok := key.(*object.String); ok {
+		return fmt.Sprintf("%q", s.Value)
+	}
+	return key.Inspect()
+}
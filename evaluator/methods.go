@@ -0,0 +1,293 @@
+package evaluator
+
+import (
+	"intInGo/ast"
+	"intInGo/diag"
+	"intInGo/object"
+	"strings"
+)
+
+// methods of the built-in types, called as recv.name(args). the receiver
+// is passed as the first argument like it is to the builtins methods fall
+// back to
+var methods = map[object.ObjectType]map[string]*object.BuiltIn{
+	object.STRING_OBJ: {
+		"len": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("len", args, 0); err != nil {
+					return err
+				}
+				return &object.Integer{Value: int64(len(args[0].(*object.String).Value))}
+			},
+		},
+		"upper": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("upper", args, 0); err != nil {
+					return err
+				}
+				return &object.String{Value: strings.ToUpper(args[0].(*object.String).Value)}
+			},
+		},
+		"lower": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("lower", args, 0); err != nil {
+					return err
+				}
+				return &object.String{Value: strings.ToLower(args[0].(*object.String).Value)}
+			},
+		},
+		"trim": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("trim", args, 0); err != nil {
+					return err
+				}
+				return &object.String{Value: strings.TrimSpace(args[0].(*object.String).Value)}
+			},
+		},
+		"split": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("split", args, 1, object.STRING_OBJ); err != nil {
+					return err
+				}
+				parts := strings.Split(args[0].(*object.String).Value, args[1].(*object.String).Value)
+				elements := make([]object.Object, len(parts))
+				for i, part := range parts {
+					elements[i] = &object.String{Value: part}
+				}
+				return &object.Array{Elements: elements}
+			},
+		},
+		"contains": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("contains", args, 1, object.STRING_OBJ); err != nil {
+					return err
+				}
+				return nativeBoolToBooleanObject(strings.Contains(args[0].(*object.String).Value, args[1].(*object.String).Value))
+			},
+		},
+		"starts_with": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("starts_with", args, 1, object.STRING_OBJ); err != nil {
+					return err
+				}
+				return nativeBoolToBooleanObject(strings.HasPrefix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+			},
+		},
+		"ends_with": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("ends_with", args, 1, object.STRING_OBJ); err != nil {
+					return err
+				}
+				return nativeBoolToBooleanObject(strings.HasSuffix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+			},
+		},
+		"replace": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("replace", args, 2, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+					return err
+				}
+				s := args[0].(*object.String).Value
+				return &object.String{Value: strings.ReplaceAll(s, args[1].(*object.String).Value, args[2].(*object.String).Value)}
+			},
+		},
+	},
+	object.ARRAY_OBJ: {
+		"join": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("join", args, 1, object.STRING_OBJ); err != nil {
+					return err
+				}
+				elements := args[0].(*object.Array).Elements
+				parts := make([]string, len(elements))
+				for i, el := range elements {
+					parts[i] = el.Inspect()
+				}
+				return &object.String{Value: strings.Join(parts, args[1].(*object.String).Value)}
+			},
+		},
+		"contains": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("contains", args, 1); err != nil {
+					return err
+				}
+				return nativeBoolToBooleanObject(indexOf(args[0].(*object.Array), args[1]) >= 0)
+			},
+		},
+		"index_of": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("index_of", args, 1); err != nil {
+					return err
+				}
+				return &object.Integer{Value: int64(indexOf(args[0].(*object.Array), args[1]))}
+			},
+		},
+		"reverse": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("reverse", args, 0); err != nil {
+					return err
+				}
+				elements := args[0].(*object.Array).Elements
+				reversed := make([]object.Object, len(elements))
+				for i, el := range elements {
+					reversed[len(elements)-1-i] = el
+				}
+				return &object.Array{Elements: reversed}
+			},
+		},
+	},
+	object.HASH_OBJ: {
+		"len": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("len", args, 0); err != nil {
+					return err
+				}
+				return &object.Integer{Value: int64(len(args[0].(*object.Hash).Pairs))}
+			},
+		},
+		"keys": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("keys", args, 0); err != nil {
+					return err
+				}
+				pairs := args[0].(*object.Hash).Sorted()
+				keys := make([]object.Object, len(pairs))
+				for i, pair := range pairs {
+					keys[i] = pair.Key
+				}
+				return &object.Array{Elements: keys}
+			},
+		},
+		"values": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("values", args, 0); err != nil {
+					return err
+				}
+				pairs := args[0].(*object.Hash).Sorted()
+				values := make([]object.Object, len(pairs))
+				for i, pair := range pairs {
+					values[i] = pair.Value
+				}
+				return &object.Array{Elements: values}
+			},
+		},
+		"has": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("has", args, 1); err != nil {
+					return err
+				}
+				key, ok := args[1].(object.Hashable)
+				if !ok {
+					return newCodedError(diag.UnhashableKey, "unusable as hash key: %s", args[1].Type())
+				}
+				_, ok = args[0].(*object.Hash).Pairs[key.HashKey()]
+				return nativeBoolToBooleanObject(ok)
+			},
+		},
+	},
+	object.ITERATOR_OBJ: {
+		// {"done": false, "value": v} for the next value, or
+		// {"done": true, "value": null} once there are none left
+		"next": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("next", args, 0); err != nil {
+					return err
+				}
+				_, value, ok := args[0].(object.Iterator).Next()
+				if !ok {
+					value = NULL
+				} else if isError(value) {
+					return value
+				}
+				done := &object.String{Value: "done"}
+				val := &object.String{Value: "value"}
+				return &object.Hash{Pairs: map[object.HashKey]object.HashPair{
+					done.HashKey(): {Key: done, Value: nativeBoolToBooleanObject(!ok)},
+					val.HashKey():  {Key: val, Value: value},
+				}}
+			},
+		},
+		// the remaining values in an array
+		"collect": &object.BuiltIn{
+			Fn: func(ctx *object.Context, args ...object.Object) object.Object {
+				if err := methodArgs("collect", args, 0); err != nil {
+					return err
+				}
+				elements := []object.Object{}
+				iter := args[0].(object.Iterator)
+				for {
+					_, value, ok := iter.Next()
+					if !ok {
+						return &object.Array{Elements: elements}
+					}
+					if isError(value) {
+						return value
+					}
+					elements = append(elements, value)
+				}
+			},
+		},
+	},
+}
+
+// check the arguments a method got besides its receiver and their types
+func methodArgs(name string, args []object.Object, want int, types ...object.ObjectType) *object.Error {
+	if len(args)-1 != want {
+		return newCodedError(diag.WrongArgumentCount, "wrong number of arguments to `%s`. got=%d, want=%d", name, len(args)-1, want)
+	}
+	for i, typ := range types {
+		if args[i+1].Type() != typ {
+			return newError("argument %d to `%s` must be %s, got %s", i+1, name, typ, args[i+1].Type())
+		}
+	}
+	return nil
+}
+
+func indexOf(arr *object.Array, obj object.Object) int {
+	for i, el := range arr.Elements {
+		if objectsEqual(el, obj) {
+			return i
+		}
+	}
+	return -1
+}
+
+// call recv.name(args): a function stored in a struct field, a method of
+// the receiver's type or a builtin taking the receiver first
+func evalMethodCall(
+	node *ast.CallExpression,
+	member *ast.MemberExpression,
+	env *object.Environment,
+) object.Object {
+	recv := Eval(member.Object, env)
+	if isError(recv) {
+		return recv
+	}
+	name := member.Property.Value
+	// s.f?() skips the call like f?() does when the field is null
+	if node.Optional && recv.Type() == object.STRUCT_OBJ {
+		if s, err := structField(recv, name); err == nil && s.Get(name) == NULL {
+			return NULL
+		}
+	}
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	if recv.Type() == object.STRUCT_OBJ {
+		s, err := structField(recv, name)
+		if err != nil {
+			return err
+		}
+		return applyFunction(s.Get(name), args, env.Context())
+	}
+
+	fn, ok := methods[recv.Type()][name]
+	if !ok {
+		fn, ok = lookupBuiltin(env.Context(), name)
+	}
+	if !ok {
+		return newCodedError(diag.UnknownMethod, "unknown method %s for %s", name, recv.Type())
+	}
+	return applyFunction(fn, append([]object.Object{recv}, args...), env.Context())
+}
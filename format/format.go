@@ -0,0 +1,702 @@
+// format/format.go
+
+package format
+
+import (
+	"intInGo/ast"
+	"intInGo/diag"
+	"intInGo/lexer"
+	"intInGo/parser"
+	"intInGo/token"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultWidth = 80 // line width lists are wrapped at
+	TabWidth     = 4  // columns a tab counts as when measuring lines
+)
+
+type Options struct {
+	Width int // line width, DefaultWidth when zero
+}
+
+// format monkey source, refusing sources that don't parse
+func Source(src string, opts Options) (string, []diag.Diagnostic) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if diags := p.Diagnostics(); len(diags) != 0 {
+		return "", diags
+	}
+
+	return Program(program, src, opts), nil
+}
+
+// pretty-print a program with canonical indentation and minimal
+// parentheses. the source the program was parsed from is used to keep
+// comments and blank lines, it may be empty for programs built by hand
+func Program(program *ast.Program, src string, opts Options) string {
+	if opts.Width <= 0 {
+		opts.Width = DefaultWidth
+	}
+
+	p := &printer{width: opts.Width}
+	p.scan(src)
+
+	var out strings.Builder
+	p.statements(&out, program.Statements, 0, false)
+	p.flushComments(&out, len(src)+1, 0)
+
+	if out.Len() == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(out.String(), "\n") + "\n"
+}
+
+// pretty-print a single expression built without source
+func Expression(exp ast.Expression, opts Options) string {
+	if opts.Width <= 0 {
+		opts.Width = DefaultWidth
+	}
+
+	p := &printer{width: opts.Width}
+	p.scan("")
+	return p.expr(exp, 0, 0)
+}
+
+type printer struct {
+	width int
+
+	tokens   []token.Token // tokens of the source, to find brackets and line breaks
+	comments []token.Token // comments of the source
+	trailing []bool        // whether a comment follows code on the same line
+	next     int           // index of the next comment to print
+
+	// number of lists printed one item per line, outside of blocks
+	broken int
+}
+
+// tokenize the source to learn where tokens and comments are
+func (p *printer) scan(src string) {
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		p.tokens = append(p.tokens, tok)
+	}
+
+	p.comments = l.Comments()
+	p.trailing = make([]bool, len(p.comments))
+	for i, c := range p.comments {
+		if prev, ok := p.tokenBefore(c.Pos.Offset); ok {
+			p.trailing[i] = prev.End.Line == c.Pos.Line
+		}
+	}
+}
+
+// last token ending at or before offset
+func (p *printer) tokenBefore(offset int) (token.Token, bool) {
+	i := sort.Search(len(p.tokens), func(i int) bool {
+		return p.tokens[i].End.Offset > offset
+	})
+	if i == 0 {
+		return token.Token{}, false
+	}
+	return p.tokens[i-1], true
+}
+
+// first token starting at or after offset
+func (p *printer) tokenAfter(offset int) (token.Token, bool) {
+	i := sort.Search(len(p.tokens), func(i int) bool {
+		return p.tokens[i].Pos.Offset >= offset
+	})
+	if i == len(p.tokens) {
+		return token.Token{}, false
+	}
+	return p.tokens[i], true
+}
+
+// token closing the bracket opened by the given token
+func (p *printer) closing(open token.Token) token.Token {
+	closeType := closer(open.Type)
+
+	i := sort.Search(len(p.tokens), func(i int) bool {
+		return p.tokens[i].Pos.Offset >= open.Pos.Offset
+	})
+
+	depth := 0
+	for ; i < len(p.tokens); i++ {
+		switch t := p.tokens[i].Type; {
+		case t == closeType:
+			depth--
+			if depth == 0 {
+				return p.tokens[i]
+			}
+		case closer(t) == closeType:
+			depth++
+		}
+	}
+
+	// no source to look at, everything counts as inside the bracket
+	return token.Token{Pos: token.Position{Offset: int(^uint(0) >> 1)}}
+}
+
+// type of the token closing a bracket of the given type, ?( and ?[ are
+// closed like ( and [
+func closer(open token.TokenType) token.TokenType {
+	switch open {
+	case token.LPAREN, token.OPTIONAL_LPAREN:
+		return token.RPAREN
+	case token.LBRACKET, token.OPTIONAL_LBRACKET:
+		return token.RBRACKET
+	case token.LBRACE:
+		return token.RBRACE
+	}
+	return ""
+}
+
+// whether a blank line separates the source at pos from what precedes it
+func (p *printer) blankLineBefore(pos token.Position) bool {
+	if len(p.tokens) == 0 {
+		return false
+	}
+
+	line := 0
+	if tok, ok := p.tokenBefore(pos.Offset); ok {
+		line = tok.End.Line
+	}
+	for _, c := range p.comments {
+		if c.End.Offset <= pos.Offset && c.End.Line > line {
+			line = c.End.Line
+		}
+	}
+
+	return line > 0 && pos.Line-line > 1
+}
+
+// start a new line for an item found at pos in the source, keeping a single
+// blank line where the source had one or more
+func (p *printer) newline(out *strings.Builder, pos token.Position, indent int) {
+	if out.Len() > 0 && p.blankLineBefore(pos) {
+		out.WriteString("\n")
+	}
+	out.WriteString("\n")
+	out.WriteString(strings.Repeat("\t", indent))
+}
+
+// print the comments found before offset, trailing comments stay on the
+// line of the code they follow
+func (p *printer) flushComments(out *strings.Builder, offset int, indent int) {
+	for p.next < len(p.comments) && p.comments[p.next].Pos.Offset < offset {
+		c := p.comments[p.next]
+		if p.trailing[p.next] {
+			out.WriteString(" ")
+		} else {
+			p.newline(out, c.Pos, indent)
+		}
+		out.WriteString(c.Literal)
+		p.next++
+	}
+}
+
+// whether comments are waiting to be printed before offset
+func (p *printer) commentsBefore(offset int) bool {
+	return p.next < len(p.comments) && p.comments[p.next].Pos.Offset < offset
+}
+
+func (p *printer) statements(out *strings.Builder, stmts []ast.Statement, indent int, inBlock bool) {
+	for i, stmt := range stmts {
+		tok := statementToken(stmt)
+		p.flushComments(out, tok.Pos.Offset, indent)
+		p.newline(out, tok.Pos, indent)
+
+		last := inBlock && i == len(stmts)-1
+		out.WriteString(p.statement(stmt, indent, last))
+	}
+}
+
+func statementToken(stmt ast.Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token
+	case *ast.ReturnStatement:
+		return stmt.Token
+	case *ast.ExpressionStatement:
+		return stmt.Token
+	case *ast.BlockStatement:
+		return stmt.Token
+	case *ast.StructStatement:
+		return stmt.Token
+	case *ast.ForStatement:
+		return stmt.Token
+	case *ast.BreakStatement:
+		return stmt.Token
+	case *ast.ContinueStatement:
+		return stmt.Token
+	}
+	return token.Token{}
+}
+
+// print a statement, the last statement of a block produces the block's
+// value and goes without a semicolon
+func (p *printer) statement(stmt ast.Statement, indent int, last bool) string {
+	col := indent * TabWidth
+
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		prefix := "let "
+		if stmt.Pattern != nil {
+			prefix += stmt.Pattern.String()
+		} else {
+			prefix += stmt.Name.Value
+		}
+		if stmt.Type != nil {
+			prefix += ": " + stmt.Type.String()
+		}
+		prefix += " = "
+		return prefix + p.expr(stmt.Value, indent, col+len(prefix)) + ";"
+
+	case *ast.ReturnStatement:
+		prefix := "return "
+		return prefix + p.expr(stmt.ReturnValue, indent, col+len(prefix)) + ";"
+
+	case *ast.ExpressionStatement:
+		text := p.expr(stmt.Expression, indent, col)
+		switch stmt.Expression.(type) {
+		case *ast.IfExpression, *ast.MatchExpression, *ast.SelectExpression:
+			return text
+		}
+		if last {
+			return text
+		}
+		return text + ";"
+
+	case *ast.BlockStatement:
+		return p.block(stmt, indent)
+
+	case *ast.StructStatement:
+		fields := make([]string, len(stmt.Fields))
+		for i, field := range stmt.Fields {
+			fields[i] = field.Value
+		}
+		if len(fields) == 0 {
+			return "struct " + stmt.Name.Value + " {}"
+		}
+		return "struct " + stmt.Name.Value + " { " + strings.Join(fields, ", ") + " }"
+
+	case *ast.ForStatement:
+		out := "for ("
+		if stmt.Key != nil {
+			out += stmt.Key.Value + ", "
+		}
+		out += stmt.Value.Value + " in "
+		out += p.expr(stmt.Iterable, indent, col+len(out))
+		return out + ") " + p.block(stmt.Body, indent)
+
+	case *ast.BreakStatement:
+		return "break;"
+
+	case *ast.ContinueStatement:
+		return "continue;"
+	}
+
+	return stmt.String()
+}
+
+func (p *printer) block(block *ast.BlockStatement, indent int) string {
+	// lists broken inside the block don't affect the lists around it
+	broken := p.broken
+	defer func() { p.broken = broken }()
+
+	var body strings.Builder
+	p.statements(&body, block.Statements, indent+1, true)
+	p.flushComments(&body, p.closing(block.Token).Pos.Offset, indent+1)
+
+	if body.Len() == 0 {
+		return "{}"
+	}
+	return "{" + body.String() + "\n" + strings.Repeat("\t", indent) + "}"
+}
+
+// print an expression starting at column col
+func (p *printer) expr(exp ast.Expression, indent, col int) string {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		return exp.Value
+
+	case *ast.IntegerLiteral:
+		return exp.Token.Literal
+
+	case *ast.DecimalLiteral:
+		return exp.Token.Literal
+
+	case *ast.Boolean:
+		return exp.Token.Literal
+
+	case *ast.NullLiteral:
+		return exp.Token.Literal
+
+	case *ast.StringLiteral:
+		return `"` + exp.Value + `"`
+
+	case *ast.PrefixExpression:
+		return exp.Operator + p.operand(exp.Right, parser.PREFIX, false, indent, col+len(exp.Operator))
+
+	case *ast.InfixExpression:
+		prec := parser.Precedence(exp.Token.Type)
+		left := p.operand(exp.Left, prec, false, indent, col)
+		op := " " + exp.Operator + " "
+		right := p.operand(exp.Right, prec, true, indent, advance(col, left+op))
+		return left + op + right
+
+	case *ast.CallExpression:
+		function := p.operand(exp.Function, postfix, false, indent, col)
+		items := make([]listItem, len(exp.Arguments))
+		for i, arg := range exp.Arguments {
+			items[i] = p.exprItem(arg)
+		}
+		return function + p.list(exp.Token.Literal, ")", exp.Token, items, indent, advance(col, function))
+
+	case *ast.IndexExpression:
+		left := p.operand(exp.Left, postfix, false, indent, col) + exp.Token.Literal
+		return left + p.expr(exp.Index, indent, advance(col, left)) + "]"
+
+	case *ast.MemberExpression:
+		return p.operand(exp.Object, postfix, false, indent, col) + "." + exp.Property.Value
+
+	case *ast.AssignExpression:
+		target := p.operand(exp.Target, parser.ASSIGN, true, indent, col) + " = "
+		return target + p.expr(exp.Value, indent, advance(col, target))
+
+	case *ast.YieldExpression:
+		return "yield " + p.expr(exp.Value, indent, col+len("yield "))
+
+	case *ast.SelectExpression:
+		return p.selectCases(exp, indent)
+
+	case *ast.SliceExpression:
+		out := p.operand(exp.Left, postfix, false, indent, col) + exp.Token.Literal
+		if exp.Start != nil {
+			out += p.expr(exp.Start, indent, advance(col, out))
+		}
+		out += ":"
+		if exp.End != nil {
+			out += p.expr(exp.End, indent, advance(col, out))
+		}
+		return out + "]"
+
+	case *ast.ArrayLiteral:
+		items := make([]listItem, len(exp.Elements))
+		for i, el := range exp.Elements {
+			items[i] = p.exprItem(el)
+		}
+		return p.list("[", "]", exp.Token, items, indent, col)
+
+	case *ast.HashLiteral:
+		items := make([]listItem, len(exp.Keys))
+		for i, key := range exp.Keys {
+			key, value := key, exp.Pairs[key]
+			items[i] = listItem{
+				pos: startPos(key),
+				print: func(indent, col int) string {
+					k := p.expr(key, indent, col) + ": "
+					return k + p.expr(value, indent, advance(col, k))
+				},
+			}
+		}
+		return p.list("{", "}", exp.Token, items, indent, col)
+
+	case *ast.FunctionLiteral:
+		params := make([]string, 0, len(exp.Parameters)+1)
+		for _, param := range exp.Parameters {
+			s := param.Value
+			if typ, ok := exp.Types[param]; ok {
+				s += ": " + typ.String()
+			}
+			if def, ok := exp.Defaults[param]; ok {
+				s += " = " + p.expr(def, indent, col)
+			}
+			params = append(params, s)
+		}
+		if exp.Rest != nil {
+			s := "..." + exp.Rest.Value
+			if typ, ok := exp.Types[exp.Rest]; ok {
+				s += ": " + typ.String()
+			}
+			params = append(params, s)
+		}
+		result := ""
+		if exp.ReturnType != nil {
+			result = ": " + exp.ReturnType.String()
+		}
+		return "fn(" + strings.Join(params, ", ") + ")" + result + " " + p.block(exp.Body, indent)
+
+	case *ast.IfExpression:
+		out := "if ("
+		out += p.expr(exp.Condition, indent, col+len(out))
+		out += ") " + p.block(exp.Consequence, indent)
+		if exp.Alternative != nil {
+			out += " else " + p.block(exp.Alternative, indent)
+		}
+		return out
+
+	case *ast.MatchExpression:
+		return p.match(exp, indent, col)
+	}
+
+	return exp.String()
+}
+
+// print a match expression with one arm per line
+func (p *printer) match(exp *ast.MatchExpression, indent, col int) string {
+	out := "match ("
+	out += p.expr(exp.Subject, indent, col+len(out)) + ") {"
+
+	// the arms end at the brace following the subject's parentheses,
+	// without source everything counts as inside
+	end := int(^uint(0) >> 1)
+	if lparen, ok := p.tokenAfter(exp.Token.End.Offset); ok {
+		if lbrace, ok := p.tokenAfter(p.closing(lparen).End.Offset); ok {
+			end = p.closing(lbrace).Pos.Offset
+		}
+	}
+
+	var body strings.Builder
+	for _, arm := range exp.Arms {
+		pos := patternPos(arm.Pattern)
+		p.flushComments(&body, pos.Offset, indent+1)
+		p.newline(&body, pos, indent+1)
+
+		text := arm.Pattern.String()
+		if arm.Guard != nil {
+			text += " if "
+			text += p.expr(arm.Guard, indent+1, advance((indent+1)*TabWidth, text))
+		}
+		text += " => "
+		text += p.expr(arm.Body, indent+1, advance((indent+1)*TabWidth, text)) + ","
+		body.WriteString(text)
+	}
+	p.flushComments(&body, end, indent+1)
+
+	if body.Len() == 0 {
+		return out + "}"
+	}
+	return out + body.String() + "\n" + strings.Repeat("\t", indent) + "}"
+}
+
+// print a select with a case per line like a match. operations are
+// printed as calls of the recv and send builtins
+func (p *printer) selectCases(exp *ast.SelectExpression, indent int) string {
+	out := "select {"
+
+	end := int(^uint(0) >> 1)
+	if lbrace, ok := p.tokenAfter(exp.Token.End.Offset); ok {
+		end = p.closing(lbrace).Pos.Offset
+	}
+
+	var body strings.Builder
+	for _, c := range exp.Cases {
+		pos := c.Op.Pos
+		if c.Name != nil {
+			pos = c.Name.Token.Pos
+		}
+		p.flushComments(&body, pos.Offset, indent+1)
+		p.newline(&body, pos, indent+1)
+
+		col := (indent + 1) * TabWidth
+		var text string
+		switch {
+		case c.Channel == nil:
+			text = "_"
+		case c.Value != nil:
+			text = "send("
+			text += p.expr(c.Channel, indent+1, advance(col, text)) + ", "
+			text += p.expr(c.Value, indent+1, advance(col, text)) + ")"
+		default:
+			if c.Name != nil {
+				text = c.Name.Value + " = "
+			}
+			text += "recv("
+			text += p.expr(c.Channel, indent+1, advance(col, text)) + ")"
+		}
+		text += " => "
+		text += p.expr(c.Body, indent+1, advance(col, text)) + ","
+		body.WriteString(text)
+	}
+	p.flushComments(&body, end, indent+1)
+
+	if body.Len() == 0 {
+		return out + "}"
+	}
+	return out + body.String() + "\n" + strings.Repeat("\t", indent) + "}"
+}
+
+// position of the first token of a pattern
+func patternPos(pattern ast.Pattern) token.Position {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		return startPos(pattern.Value)
+	case *ast.WildcardPattern:
+		return pattern.Token.Pos
+	case *ast.BindingPattern:
+		return pattern.Name.Token.Pos
+	case *ast.ArrayPattern:
+		return pattern.Token.Pos
+	case *ast.HashPattern:
+		return pattern.Token.Pos
+	}
+	return token.Position{}
+}
+
+// print an operand of an operator binding with precedence prec, wrapped in
+// parentheses if it binds looser. operators are left associative, so right
+// operands of equal precedence need parentheses too
+func (p *printer) operand(exp ast.Expression, prec int, right bool, indent, col int) string {
+	own := precedence(exp)
+	if own < prec || (right && own == prec) {
+		return "(" + p.expr(exp, indent, col+1) + ")"
+	}
+	return p.expr(exp, indent, col)
+}
+
+// calls, indexes, slices and member accesses all apply to the expression
+// on their left, so they chain without parentheses like f(1)[0].x
+const postfix = parser.CALL
+
+// precedence an expression was parsed with, literals and other primary
+// expressions bind tightest
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(exp.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression, *ast.IndexExpression, *ast.SliceExpression, *ast.MemberExpression:
+		return postfix
+	case *ast.AssignExpression:
+		return parser.ASSIGN
+	case *ast.YieldExpression:
+		return parser.LOWEST
+	}
+	return postfix + 1
+}
+
+// position of the first token of an expression
+func startPos(exp ast.Expression) token.Position {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return startPos(exp.Left)
+	case *ast.CallExpression:
+		return startPos(exp.Function)
+	case *ast.IndexExpression:
+		return startPos(exp.Left)
+	case *ast.SliceExpression:
+		return startPos(exp.Left)
+	case *ast.MemberExpression:
+		return startPos(exp.Object)
+	case *ast.AssignExpression:
+		return startPos(exp.Target)
+	case *ast.Identifier:
+		return exp.Token.Pos
+	case *ast.IntegerLiteral:
+		return exp.Token.Pos
+	case *ast.DecimalLiteral:
+		return exp.Token.Pos
+	case *ast.Boolean:
+		return exp.Token.Pos
+	case *ast.NullLiteral:
+		return exp.Token.Pos
+	case *ast.StringLiteral:
+		return exp.Token.Pos
+	case *ast.PrefixExpression:
+		return exp.Token.Pos
+	case *ast.ArrayLiteral:
+		return exp.Token.Pos
+	case *ast.HashLiteral:
+		return exp.Token.Pos
+	case *ast.FunctionLiteral:
+		return exp.Token.Pos
+	case *ast.IfExpression:
+		return exp.Token.Pos
+	case *ast.MatchExpression:
+		return exp.Token.Pos
+	case *ast.YieldExpression:
+		return exp.Token.Pos
+	case *ast.SelectExpression:
+		return exp.Token.Pos
+	}
+	return token.Position{}
+}
+
+// element of an argument list, array or hash literal
+type listItem struct {
+	pos   token.Position // where the item starts in the source
+	print func(indent, col int) string
+}
+
+func (p *printer) exprItem(exp ast.Expression) listItem {
+	return listItem{
+		pos: startPos(exp),
+		print: func(indent, col int) string {
+			return p.expr(exp, indent, col)
+		},
+	}
+}
+
+// print a bracketed, comma separated list on one line if it fits and has no
+// comments inside, otherwise with one item per line. outer lists are broken
+// before the lists nested in them
+func (p *printer) list(open, close string, openTok token.Token, items []listItem, indent, col int) string {
+	end := p.closing(openTok).Pos.Offset
+
+	if !p.commentsBefore(end) {
+		broken := p.broken
+		out := open
+		for i, item := range items {
+			if i > 0 {
+				out += ", "
+			}
+			out += item.print(indent, advance(col, out))
+		}
+		out += close
+
+		if len(items) == 0 || (p.broken == broken && fits(col, out, p.width)) {
+			return out
+		}
+		p.broken = broken
+	}
+	p.broken++
+
+	var out strings.Builder
+	out.WriteString(open)
+	for i, item := range items {
+		p.flushComments(&out, item.pos.Offset, indent+1)
+		p.newline(&out, item.pos, indent+1)
+		out.WriteString(item.print(indent+1, (indent+1)*TabWidth))
+		if i < len(items)-1 {
+			out.WriteString(",")
+		}
+	}
+	p.flushComments(&out, end, indent+1)
+	out.WriteString("\n" + strings.Repeat("\t", indent) + close)
+
+	return out.String()
+}
+
+// whether the first line of s fits when printed from column col
+func fits(col int, s string, width int) bool {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return col+measure(s) <= width
+}
+
+// column reached after printing s from column col
+func advance(col int, s string) int {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return measure(s[i+1:])
+	}
+	return col + measure(s)
+}
+
+func measure(s string) int {
+	return len(s) + strings.Count(s, "\t")*(TabWidth-1)
+}
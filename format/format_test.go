@@ -0,0 +1,126 @@
+// format/format_test.go
+
+package format
+
+import (
+	"intInGo/ast"
+	"intInGo/lexer"
+	"intInGo/parser"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		width    int
+		expected string
+	}{
+		{"let   x=1+2*3", 0, "let x = 1 + 2 * 3;\n"},
+		{"let x = (1 + 2) * 3;", 0, "let x = (1 + 2) * 3;\n"},
+		{"let x = 1 - (2 - 3);", 0, "let x = 1 - (2 - 3);\n"},
+		{"let x = (1 - 2) - 3;", 0, "let x = 1 - 2 - 3;\n"},
+		{"let x = ((a));", 0, "let x = a;\n"},
+		{"-(a + b)", 0, "-(a + b);\n"},
+		{"return x", 0, "return x;\n"},
+		{"a;b", 0, "a;\nb;\n"},
+		{"if (x) { 1 } else { 2 }", 0, "if (x) {\n\t1\n} else {\n\t2\n}\n"},
+		{"let f = fn(a,b){a+b;};", 0, "let f = fn(a, b) {\n\ta + b\n};\n"},
+		{"fn() {}", 0, "fn() {};\n"},
+		{"fn(a,b=1+2,...rest){}", 0, "fn(a, b = 1 + 2, ...rest) {};\n"},
+		{"let f:fn(int):int=fn(a:int,b:string=\"x\",...r:array<int>):int{a}", 0, "let f: fn(int): int = fn(a: int, b: string = \"x\", ...r: array<int>): int {\n\ta\n};\n"},
+		{"let a = 1;\n\n\n\nlet b = 2;", 0, "let a = 1;\n\nlet b = 2;\n"},
+		{"// header\nlet a = 1; // one\n// footer", 0, "// header\nlet a = 1; // one\n// footer\n"},
+		{`{"b": 1, "a": 2}`, 0, "{\"b\": 1, \"a\": 2};\n"},
+		{"a[1:2][:3]", 0, "a[1:2][:3];\n"},
+		{"let a = f(1)[0];", 0, "let a = f(1)[0];\n"},
+		{"f(1)[0:1]", 0, "f(1)[0:1];\n"},
+		{"(f)(1)[a[0]](2)", 0, "f(1)[a[0]](2);\n"},
+		{"(-a)[0]", 0, "(-a)[0];\n"},
+		{"let x = f().x;", 0, "let x = f().x;\n"},
+		{"(p.q[0]).r = (-a).b", 0, "p.q[0].r = (-a).b;\n"},
+		{"[1, 2].push(3).len()", 0, "[1, 2].push(3).len();\n"},
+		{`"a b".split(" ").join("-").upper()`, 0, "\"a b\".split(\" \").join(\"-\").upper();\n"},
+		{"let total=12.50d*-(2+0.5d)", 0, "let total = 12.50d * -(2 + 0.5d);\n"},
+		{"match (p) { -1.50d=>0, _=>p.round(1) }", 0, "match (p) {\n\t-1.50d => 0,\n\t_ => p.round(1),\n}\n"},
+		{"let v=h?[\"a\"]?[1:]??f?(null,2)", 0, "let v = h?[\"a\"]?[1:] ?? f?(null, 2);\n"},
+		{"(a??b)??c;a??(b??c);(a??b)==c", 0, "a ?? b ?? c;\na ?? (b ?? c);\n(a ?? b) == c;\n"},
+		{"f?([1, 2], [3, 4])", 15, "f?(\n\t[1, 2],\n\t[3, 4]\n);\n"},
+		{"[1, 2, 3]", 8, "[\n\t1,\n\t2,\n\t3\n];\n"},
+		{"f([1, 2], [3, 4])", 14, "f(\n\t[1, 2],\n\t[3, 4]\n);\n"},
+		{"[1, // one\n2]", 0, "[\n\t1, // one\n\t2\n];\n"},
+		{"struct Point{x,y,}\nlet p=Point(1,2);p.x=(p.y+1)", 0, "struct Point { x, y }\nlet p = Point(1, 2);\np.x = p.y + 1;\n"},
+		{"(p.x = 1) + 2", 0, "(p.x = 1) + 2;\n"},
+		{"match (x) { 1 => \"a\", [h, ...t] if h>1 => t, _ => 0 }", 0, "match (x) {\n\t1 => \"a\",\n\t[h, ...t] if h > 1 => t,\n\t_ => 0,\n}\n"},
+		{"let y = match(x){}", 0, "let y = match (x) {};\n"},
+		{"let [a,...b]=xs;let {\"k\":kk,name}=h;fn([x,y],{z}){x}", 0, "let [a, ...b] = xs;\nlet {\"k\": kk, name} = h;\nfn([x, y], {z}) {\n\tx\n};\n"},
+		{"let g=fn(){let x=yield 1+2;f(yield(x))}", 0, "let g = fn() {\n\tlet x = yield 1 + 2;\n\tf(yield x)\n};\n"},
+		{"let x=select{v=c.recv()=>v+1, // got one\nsend(d,2)=>0,_=>1}", 0, "let x = select {\n\tv = recv(c) => v + 1, // got one\n\tsend(d, 2) => 0,\n\t_ => 1,\n};\n"},
+		{"select {}", 0, "select {}\n"},
+		{"fn(){1+(yield 2)}", 0, "fn() {\n\t1 + (yield 2)\n};\n"},
+		{"for(k,v in h){if(v){break}continue;}for(x in range(3)){}", 0, "for (k, v in h) {\n\tif (v) {\n\t\tbreak;\n\t}\n\tcontinue;\n}\nfor (x in range(3)) {}\n"},
+	}
+
+	for _, tt := range tests {
+		out, diags := Source(tt.input, Options{Width: tt.width})
+		if diags != nil {
+			t.Fatalf("unexpected diagnostics for %q: %v", tt.input, diags)
+		}
+		if out != tt.expected {
+			t.Errorf("wrong output for %q.\nwant=%q\ngot= %q", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestFormatIdempotentAndStable(t *testing.T) {
+	input := `// fib
+let fib = fn(n) {
+  if (n < 2) { return n; }   // base case
+  fib(n-1)+fib(n - 2)
+};
+
+let xs = [fib(1), fib(2), fib(3), fib(4), fib(5), fib(6), fib(7), fib(8), fib(9), fib(10)];
+let h = {"one": 1, "two": [1, 2], "three": fn(x) { x * (x + 1) }};
+puts(h["three"](-(1 + 2)), xs[1:]);
+`
+
+	for _, width := range []int{20, 40, DefaultWidth} {
+		once, diags := Source(input, Options{Width: width})
+		if diags != nil {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		twice, _ := Source(once, Options{Width: width})
+		if once != twice {
+			t.Errorf("formatting not idempotent at width %d.\nfirst:\n%s\nsecond:\n%s", width, once, twice)
+		}
+
+		// formatting must not change what the program means
+		if got, want := parse(t, once), parse(t, input); got != want {
+			t.Errorf("program changed at width %d.\nwant=%s\ngot= %s", width, want, got)
+		}
+	}
+}
+
+func TestFormatRefusesInvalidSource(t *testing.T) {
+	out, diags := Source("let x 5;", Options{})
+	if out != "" || len(diags) == 0 {
+		t.Errorf("expected diagnostics and no output. got=%q, %v", out, diags)
+	}
+}
+
+func parse(t *testing.T, src string) string {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program.String()
+}
+
+func TestFormatExpression(t *testing.T) {
+	program := parser.New(lexer.New("(a + b) * c[1]")).ParseProgram()
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+	if got := Expression(stmt.Expression, Options{}); got != "(a + b) * c[1]" {
+		t.Errorf("wrong output. got=%q", got)
+	}
+}
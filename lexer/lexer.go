@@ -2,24 +2,58 @@
 
 package lexer
 
-import "intInGo/token"
+import (
+	"intInGo/diag"
+	"intInGo/token"
+	"strings"
+)
 
 type Lexer struct {
 	input        string
 	position     int  // point to current char in input
 	readPosition int  // current reading position (after position)
 	ch           byte // current char -- supports ASCII (not UTF-8)
+
+	line   int // line of current char
+	column int // column of current char
+
+	diagnostics []diag.Diagnostic // illegal characters, unterminated strings
+	comments    []token.Token     // comments skipped so far, in source order
 }
 
 // create a new Lexer
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
+// problems found while tokenizing the input so far
+func (l *Lexer) Diagnostics() []diag.Diagnostic {
+	return l.diagnostics
+}
+
+// comments skipped while tokenizing the input so far
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
+// position of the current character
+func (l *Lexer) pos() token.Position {
+	return token.Position{Offset: l.position, Line: l.line, Column: l.column}
+}
+
 // give next character and advance position in input string
 func (l *Lexer) readChar() {
+	// track line and column of the character about to be read
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	if l.readPosition <= len(l.input) {
+		l.column++
+	}
+
 	// reached end of input?
 	if l.readPosition >= len(l.input) {
 		// set current character to NUL
@@ -34,6 +68,16 @@ func (l *Lexer) readChar() {
 	l.readPosition += 1
 }
 
+// report the current character as one the language doesn't use
+func (l *Lexer) illegal(start token.Position) token.Token {
+	l.diagnostics = append(l.diagnostics, diag.Errorf(
+		diag.IllegalCharacter,
+		diag.Span{Start: start, End: l.peekPos()},
+		"illegal character %q", l.ch,
+	))
+	return newToken(token.ILLEGAL, l.ch)
+}
+
 // peek ahead like readChar, but don't set anything
 func (l *Lexer) peekChar() byte {
 	if l.readPosition >= len(l.input) {
@@ -49,6 +93,8 @@ func (l *Lexer) NextToken() token.Token {
 
 	l.skipWhitespace()
 
+	start := l.pos()
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -57,6 +103,9 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			literal := string(ch) + string(l.ch)
 			tok = token.Token{Type: token.EQ, Literal: literal}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "=>"}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -81,6 +130,20 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LT, l.ch)
 	case '>':
 		tok = newToken(token.GT, l.ch)
+	case '?':
+		switch l.peekChar() {
+		case '?':
+			l.readChar()
+			tok = token.Token{Type: token.NULLISH, Literal: "??"}
+		case '[':
+			l.readChar()
+			tok = token.Token{Type: token.OPTIONAL_LBRACKET, Literal: "?["}
+		case '(':
+			l.readChar()
+			tok = token.Token{Type: token.OPTIONAL_LPAREN, Literal: "?("}
+		default:
+			tok = l.illegal(start)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case '(':
@@ -99,51 +162,96 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RBRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case 0:
+		// EOF is empty, don't advance past the end of the input
 		tok.Literal = ""
 		tok.Type = token.EOF
+		tok.Pos, tok.End = start, start
+		return tok
 	case '"':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = l.readString(start)
 	default:
 		// if reading letter, read rest of ident/keyword until non-letter
 		if isLetter(l.ch) {
 			// set token fields
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos, tok.End = start, l.pos()
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Pos, tok.End = start, l.pos()
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			tok = l.illegal(start)
 		}
 	}
 
 	// advance pointers into input
 	l.readChar()
+	tok.Pos, tok.End = start, l.pos()
 	return tok
 }
 
-func (l *Lexer) readString() string {
+// position just past the current character
+func (l *Lexer) peekPos() token.Position {
+	pos := l.pos()
+	pos.Offset++
+	pos.Column++
+	return pos
+}
+
+func (l *Lexer) readString(start token.Position) string {
 	position := l.position + 1
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
+		if l.ch == '"' {
+			break
+		}
+		if l.ch == 0 {
+			l.diagnostics = append(l.diagnostics, diag.Errorf(
+				diag.UnterminatedString,
+				diag.Span{Start: start, End: l.pos()},
+				"unterminated string literal",
+			))
 			break
 		}
 	}
 	return l.input[position:l.position]
 }
 
-// read identifier and advance lexer position until non-digit encountered
-func (l *Lexer) readNumber() string {
+// read an integer, or a decimal like 12.50d or 12d
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
+
+	// a fraction only belongs to the number when the d suffix follows it
+	end := l.position
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		end++
+		for end < len(l.input) && isDigit(l.input[end]) {
+			end++
+		}
+	}
+	if end < len(l.input) && l.input[end] == 'd' &&
+		(end+1 == len(l.input) || !isLetter(l.input[end+1]) && !isDigit(l.input[end+1])) {
+		for l.position <= end {
+			l.readChar()
+		}
+		return l.input[position:l.position], token.DECIMAL
+	}
+	return l.input[position:l.position], token.INT
 }
 
 // test if digit (only handle integers)
@@ -165,12 +273,40 @@ func isLetter(ch byte) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
 }
 
-// ignore meaningless whitespaces
+// ignore meaningless whitespaces and comments
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
-		// if whitespace, simply advance
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			// if whitespace, simply advance
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			l.readComment()
+		default:
+			return
+		}
+	}
+}
+
+// record a comment running until the end of the line
+func (l *Lexer) readComment() {
+	start := l.pos()
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+
+	literal := strings.TrimRight(l.input[position:l.position], " \t\r")
+	end := start
+	end.Offset += len(literal)
+	end.Column += len(literal)
+
+	l.comments = append(l.comments, token.Token{
+		Type:    token.COMMENT,
+		Literal: literal,
+		Pos:     start,
+		End:     end,
+	})
 }
 
 // initialize new token
@@ -3,6 +3,7 @@
 package lexer
 
 import (
+	"intInGo/diag"
 	"intInGo/token"
 	"testing"
 )
@@ -138,3 +139,193 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  \"ab\" == y"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedPos     token.Position
+		expectedEnd     token.Position
+	}{
+		{"let", token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{"x", token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{"=", token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{"5", token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 9, Line: 1, Column: 10}},
+		{";", token.Position{Offset: 9, Line: 1, Column: 10}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{"ab", token.Position{Offset: 13, Line: 2, Column: 3}, token.Position{Offset: 17, Line: 2, Column: 7}},
+		{"==", token.Position{Offset: 18, Line: 2, Column: 8}, token.Position{Offset: 20, Line: 2, Column: 10}},
+		{"y", token.Position{Offset: 21, Line: 2, Column: 11}, token.Position{Offset: 22, Line: 2, Column: 12}},
+		{"", token.Position{Offset: 22, Line: 2, Column: 12}, token.Position{Offset: 22, Line: 2, Column: 12}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Errorf("tests[%d] - pos wrong. expected=%+v, got=%+v", i, tt.expectedPos, tok.Pos)
+		}
+		if tok.End != tt.expectedEnd {
+			t.Errorf("tests[%d] - end wrong. expected=%+v, got=%+v", i, tt.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestLexerDiagnostics(t *testing.T) {
+	l := New("let a = @;\n\"open")
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+
+	diags := l.Diagnostics()
+	if len(diags) != 2 {
+		t.Fatalf("wrong number of diagnostics. want=2, got=%d (%v)", len(diags), diags)
+	}
+
+	if diags[0].Code != diag.IllegalCharacter || diags[0].Message != "illegal character '@'" {
+		t.Errorf("wrong first diagnostic. got=%v", diags[0])
+	}
+	if diags[0].Span.Start.String() != "1:9" {
+		t.Errorf("wrong position for first diagnostic. got=%s", diags[0].Span.Start)
+	}
+
+	if diags[1].Code != diag.UnterminatedString || diags[1].Message != "unterminated string literal" {
+		t.Errorf("wrong second diagnostic. got=%v", diags[1])
+	}
+	if diags[1].Span.Start.String() != "2:1" {
+		t.Errorf("wrong position for second diagnostic. got=%s", diags[1].Span.Start)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// header
+let a = 1; // trailing   
+a // last`
+
+	expected := []token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON, token.IDENT, token.EOF}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+
+	comments := l.Comments()
+	want := []string{"// header", "// trailing", "// last"}
+	if len(comments) != len(want) {
+		t.Fatalf("wrong number of comments. want=%d, got=%d", len(want), len(comments))
+	}
+	for i, c := range comments {
+		if c.Type != token.COMMENT || c.Literal != want[i] {
+			t.Errorf("comments[%d] wrong. want=%q, got=%q (%s)", i, want[i], c.Literal, c.Type)
+		}
+	}
+	if comments[1].Pos.String() != "2:12" {
+		t.Errorf("wrong position for comment. got=%s", comments[1].Pos)
+	}
+}
+
+func TestEllipsis(t *testing.T) {
+	l := New("fn(...rest) .. . => =")
+
+	expected := []struct {
+		typ     token.TokenType
+		literal string
+	}{
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RPAREN, ")"},
+		{token.DOT, "."},
+		{token.DOT, "."},
+		{token.DOT, "."},
+		{token.ARROW, "=>"},
+		{token.ASSIGN, "="},
+		{token.EOF, ""},
+	}
+
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.typ || tok.Literal != tt.literal {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.typ, tt.literal, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestDecimals(t *testing.T) {
+	l := New("12.50d 7d 1.5 x.len() 3.d 2dx 0.125d.round(2)")
+
+	expected := []struct {
+		typ     token.TokenType
+		literal string
+	}{
+		{token.DECIMAL, "12.50d"},
+		{token.DECIMAL, "7d"},
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.INT, "5"},
+		{token.IDENT, "x"},
+		{token.DOT, "."},
+		{token.IDENT, "len"},
+		{token.LPAREN, "("},
+		{token.RPAREN, ")"},
+		{token.INT, "3"},
+		{token.DOT, "."},
+		{token.IDENT, "d"},
+		{token.INT, "2"},
+		{token.IDENT, "dx"},
+		{token.DECIMAL, "0.125d"},
+		{token.DOT, "."},
+		{token.IDENT, "round"},
+		{token.LPAREN, "("},
+		{token.INT, "2"},
+		{token.RPAREN, ")"},
+		{token.EOF, ""},
+	}
+
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.typ || tok.Literal != tt.literal {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.typ, tt.literal, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestNullOperators(t *testing.T) {
+	l := New(`null a ?? b h?["k"] f?(1) ? x`)
+
+	expected := []struct {
+		typ     token.TokenType
+		literal string
+	}{
+		{token.NULL, "null"},
+		{token.IDENT, "a"},
+		{token.NULLISH, "??"},
+		{token.IDENT, "b"},
+		{token.IDENT, "h"},
+		{token.OPTIONAL_LBRACKET, "?["},
+		{token.STRING, "k"},
+		{token.RBRACKET, "]"},
+		{token.IDENT, "f"},
+		{token.OPTIONAL_LPAREN, "?("},
+		{token.INT, "1"},
+		{token.RPAREN, ")"},
+		{token.ILLEGAL, "?"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.typ || tok.Literal != tt.literal {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.typ, tt.literal, tok.Type, tok.Literal)
+		}
+	}
+}
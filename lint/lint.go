@@ -0,0 +1,102 @@
+// lint/lint.go
+
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"intInGo/ast"
+	"intInGo/diag"
+	"intInGo/evaluator"
+	"intInGo/resolver"
+	"io"
+	"sort"
+)
+
+// a check over a whole program, reporting what it finds through the pass
+type Rule struct {
+	Name  string
+	Doc   string // one line description
+	Check func(pass *Pass)
+}
+
+var registry = map[string]*Rule{}
+
+// make a rule available to Lint, rules are enabled unless configured off
+func Register(rule *Rule) {
+	if _, ok := registry[rule.Name]; ok {
+		panic("lint: rule registered twice: " + rule.Name)
+	}
+	registry[rule.Name] = rule
+}
+
+// all registered rules sorted by name
+func Rules() []*Rule {
+	rules := make([]*Rule, 0, len(registry))
+	for _, rule := range registry {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules
+}
+
+type Config struct {
+	// rules turned on or off by name, rules not listed are on
+	Rules map[string]bool `json:"rules"`
+}
+
+func (c Config) Enabled(rule string) bool {
+	on, ok := c.Rules[rule]
+	return !ok || on
+}
+
+// read a json config like {"rules": {"unreachable": false}}
+func ReadConfig(r io.Reader) (Config, error) {
+	var config Config
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("invalid lint config: %w", err)
+	}
+	for name := range config.Rules {
+		if _, ok := registry[name]; !ok {
+			return Config{}, fmt.Errorf("invalid lint config: unknown rule %q", name)
+		}
+	}
+	return config, nil
+}
+
+// what a rule gets to look at
+type Pass struct {
+	Program  *ast.Program
+	Resolved *resolver.Result // bindings of the program's identifiers
+
+	rule        *Rule
+	diagnostics []diag.Diagnostic
+}
+
+// report a finding of the running rule
+func (p *Pass) Report(span diag.Span, format string, a ...interface{}) {
+	d := diag.Warningf(p.rule.Name, span, format, a...)
+	p.diagnostics = append(p.diagnostics, d)
+}
+
+// run the enabled rules over a program that parsed without errors
+func Lint(program *ast.Program, config Config) []diag.Diagnostic {
+	pass := &Pass{
+		Program:  program,
+		Resolved: resolver.Resolve(program, resolver.Options{Globals: evaluator.BuiltinNames()}),
+	}
+
+	for _, rule := range Rules() {
+		if config.Enabled(rule.Name) {
+			pass.rule = rule
+			rule.Check(pass)
+		}
+	}
+
+	sort.SliceStable(pass.diagnostics, func(i, j int) bool {
+		return pass.diagnostics[i].Span.Start.Offset < pass.diagnostics[j].Span.Start.Offset
+	})
+	return pass.diagnostics
+}
@@ -0,0 +1,117 @@
+// lint/lint_test.go
+
+package lint
+
+import (
+	"intInGo/ast"
+	"intInGo/diag"
+	"intInGo/lexer"
+	"intInGo/parser"
+	"strings"
+	"testing"
+)
+
+func lint(t *testing.T, input string, config Config) []string {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	var found []string
+	for _, d := range Lint(program, config) {
+		found = append(found, d.String())
+	}
+	return found
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// arity
+		{"let f = fn(a, b) { a + b }; f(1, 2)", nil},
+		{"let f = fn(a, b) { a + b }; f(1)", []string{"1:29: warning[arity]: wrong number of arguments to f: want=2, got=1"}},
+		{"fn(x) { x }(1, 2)", []string{"1:1: warning[arity]: wrong number of arguments to function literal: want=1, got=2"}},
+		{"let f = fn(a) { a }; let f = 5; f()", nil},
+		{"let g = fn(f) { f(1, 2) }", nil},
+		{"let f = fn(a, b = 1) { a }; f(1); f(1, 2)", nil},
+		{"let f = fn(a, b = 1) { a }; f()", []string{"1:29: warning[arity]: wrong number of arguments to f: want=1..2, got=0"}},
+		{"let f = fn(a, ...rest) { a }; f(1, 2, 3)", nil},
+		{"let f = fn(a, ...rest) { a }; f()", []string{"1:31: warning[arity]: wrong number of arguments to f: want=1 or more, got=0"}},
+		// self-comparison
+		{"let x = 1; x == x", []string{"1:14: warning[self-comparison]: comparison of x with itself is always true"}},
+		{"let a = [1]; a[0] != a[0]", []string{"1:19: warning[self-comparison]: comparison of a[0] with itself is always false"}},
+		{"let f = fn() { 1 }; f() == f()", nil},
+		{"let x = 1; x + x", nil},
+		// constant-condition
+		{"if (false) { 1 }", []string{"1:5: warning[constant-condition]: if condition is always false"}},
+		{"if (!0) { 1 }", []string{"1:5: warning[constant-condition]: if condition is always false"}},
+		{"if (null) { 1 }", []string{"1:5: warning[constant-condition]: if condition is always false"}},
+		{"let x = 1; if (x) { 1 }", nil},
+		// unreachable
+		{"let f = fn() { return 1; 2 }", []string{"1:26: warning[unreachable]: unreachable code after return"}},
+		{"let f = fn() { let a = 1; return a; }", nil},
+		{"for (x in [1]) { break; x }", []string{"1:25: warning[unreachable]: unreachable code after break"}},
+		{"for (x in [1]) { if (x) { continue } x }", nil},
+		// builtin-shadowing
+		{"let len = 1;", []string{"1:5: warning[builtin-shadowing]: let len shadows builtin len"}},
+		{"let f = fn(first) { first }", []string{"1:12: warning[builtin-shadowing]: parameter first shadows builtin first"}},
+		{"for (i, last in [1]) { i }", []string{"1:9: warning[builtin-shadowing]: loop variable last shadows builtin last"}},
+	}
+
+	for _, tt := range tests {
+		found := lint(t, tt.input, Config{})
+		if strings.Join(found, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong findings for %q.\nwant=%v\ngot= %v", tt.input, tt.expected, found)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	input := "let len = 1; if (true) { len }"
+
+	config, err := ReadConfig(strings.NewReader(`{"rules": {"builtin-shadowing": false}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := lint(t, input, config)
+	if len(found) != 1 || !strings.Contains(found[0], "constant-condition") {
+		t.Errorf("disabled rule still reported. got=%v", found)
+	}
+
+	if _, err := ReadConfig(strings.NewReader(`{"rules": {"no-such-rule": true}}`)); err == nil {
+		t.Errorf("expected error for unknown rule")
+	}
+	if _, err := ReadConfig(strings.NewReader(`{"rulez": {}}`)); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	rule := &Rule{
+		Name: "no-strings",
+		Doc:  "string literals",
+		Check: func(pass *Pass) {
+			ast.Inspect(pass.Program, func(node ast.Node) bool {
+				if s, ok := node.(*ast.StringLiteral); ok {
+					pass.Report(diag.TokenSpan(s.Token), "string literal %q", s.Value)
+				}
+				return true
+			})
+		},
+	}
+	Register(rule)
+	defer delete(registry, rule.Name)
+
+	found := lint(t, `"a"`, Config{Rules: map[string]bool{"constant-condition": false}})
+	if len(found) != 1 || found[0] != `1:1: warning[no-strings]: string literal "a"` {
+		t.Errorf("registered rule not run. got=%v", found)
+	}
+
+	found = lint(t, `"a"`, Config{Rules: map[string]bool{"no-strings": false}})
+	if len(found) != 0 {
+		t.Errorf("disabled rule run. got=%v", found)
+	}
+}
@@ -0,0 +1,267 @@
+// lint/rules.go
+
+package lint
+
+import (
+	"fmt"
+	"intInGo/ast"
+	"intInGo/diag"
+	"intInGo/evaluator"
+	"intInGo/format"
+	"intInGo/token"
+)
+
+func init() {
+	Register(&Rule{
+		Name:  "arity",
+		Doc:   "calls passing the wrong number of arguments to a known function",
+		Check: checkArity,
+	})
+	Register(&Rule{
+		Name:  "self-comparison",
+		Doc:   "comparisons of a value with itself",
+		Check: checkSelfComparison,
+	})
+	Register(&Rule{
+		Name:  "constant-condition",
+		Doc:   "if expressions whose condition is a constant",
+		Check: checkConstantCondition,
+	})
+	Register(&Rule{
+		Name:  "unreachable",
+		Doc:   "statements after a return in the same block",
+		Check: checkUnreachable,
+	})
+	Register(&Rule{
+		Name:  "builtin-shadowing",
+		Doc:   "lets and parameters hiding a builtin function",
+		Check: checkBuiltinShadowing,
+	})
+}
+
+func checkArity(pass *Pass) {
+	// functions bound by a let, by the identifier of the binding
+	functions := map[*ast.Identifier]*ast.FunctionLiteral{}
+	ast.Inspect(pass.Program, func(node ast.Node) bool {
+		if let, ok := node.(*ast.LetStatement); ok && let.Name != nil {
+			if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+				functions[let.Name] = fn
+			}
+		}
+		return true
+	})
+
+	ast.Inspect(pass.Program, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+
+		var fn *ast.FunctionLiteral
+		var name string
+		tok := call.Token
+		switch callee := call.Function.(type) {
+		case *ast.Identifier:
+			fn = functions[pass.Resolved.Definitions[callee]]
+			name, tok = callee.Value, callee.Token
+		case *ast.FunctionLiteral:
+			fn, name, tok = callee, "function literal", callee.Token
+		}
+
+		if fn == nil {
+			return true
+		}
+
+		got := len(call.Arguments)
+		required := len(fn.Parameters) - len(fn.Defaults)
+		var want string
+		switch {
+		case fn.Rest != nil:
+			if got < required {
+				want = fmt.Sprintf("%d or more", required)
+			}
+		case required < len(fn.Parameters):
+			if got < required || got > len(fn.Parameters) {
+				want = fmt.Sprintf("%d..%d", required, len(fn.Parameters))
+			}
+		case got != required:
+			want = fmt.Sprintf("%d", required)
+		}
+		if want != "" {
+			pass.Report(diag.TokenSpan(tok), "wrong number of arguments to %s: want=%s, got=%d", name, want, got)
+		}
+		return true
+	})
+}
+
+func checkSelfComparison(pass *Pass) {
+	ast.Inspect(pass.Program, func(node ast.Node) bool {
+		infix, ok := node.(*ast.InfixExpression)
+		if !ok {
+			return true
+		}
+
+		var result string
+		switch infix.Operator {
+		case "==":
+			result = "true"
+		case "!=", "<", ">":
+			result = "false"
+		default:
+			return true
+		}
+
+		left := format.Expression(infix.Left, format.Options{})
+		if pure(infix.Left) && left == format.Expression(infix.Right, format.Options{}) {
+			pass.Report(diag.TokenSpan(infix.Token), "comparison of %s with itself is always %s", left, result)
+		}
+		return true
+	})
+}
+
+// expressions giving the same value every time they're evaluated
+func pure(exp ast.Expression) bool {
+	isPure := true
+	ast.Inspect(exp, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.CallExpression, *ast.FunctionLiteral, *ast.BadExpression:
+			isPure = false
+		}
+		return isPure
+	})
+	return isPure
+}
+
+func checkConstantCondition(pass *Pass) {
+	ast.Inspect(pass.Program, func(node ast.Node) bool {
+		if exp, ok := node.(*ast.IfExpression); ok {
+			if value, tok, ok := constant(exp.Condition); ok {
+				pass.Report(diag.TokenSpan(tok), "if condition is always %t", value)
+			}
+		}
+		return true
+	})
+}
+
+// truthiness of conditions known before running the program. anything
+// but false and null is truthy, so literals other than booleans and null
+// are true
+func constant(exp ast.Expression) (bool, token.Token, bool) {
+	switch exp := exp.(type) {
+	case *ast.Boolean:
+		return exp.Value, exp.Token, true
+	case *ast.NullLiteral:
+		return false, exp.Token, true
+	case *ast.IntegerLiteral:
+		return true, exp.Token, true
+	case *ast.DecimalLiteral:
+		return true, exp.Token, true
+	case *ast.StringLiteral:
+		return true, exp.Token, true
+	case *ast.ArrayLiteral:
+		return true, exp.Token, true
+	case *ast.HashLiteral:
+		return true, exp.Token, true
+	case *ast.FunctionLiteral:
+		return true, exp.Token, true
+	case *ast.PrefixExpression:
+		if exp.Operator == "!" {
+			if value, _, ok := constant(exp.Right); ok {
+				return !value, exp.Token, true
+			}
+		}
+	}
+	return false, token.Token{}, false
+}
+
+func checkUnreachable(pass *Pass) {
+	ast.Inspect(pass.Program, func(node ast.Node) bool {
+		block, ok := node.(*ast.BlockStatement)
+		if !ok {
+			return true
+		}
+		for i := 0; i+1 < len(block.Statements); i++ {
+			switch block.Statements[i].(type) {
+			case *ast.ReturnStatement, *ast.BreakStatement, *ast.ContinueStatement:
+				next := block.Statements[i+1]
+				pass.Report(diag.TokenSpan(statementToken(next)), "unreachable code after %s",
+					statementToken(block.Statements[i]).Literal)
+			default:
+				continue
+			}
+			break
+		}
+		return true
+	})
+}
+
+func statementToken(stmt ast.Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token
+	case *ast.ReturnStatement:
+		return stmt.Token
+	case *ast.ExpressionStatement:
+		return stmt.Token
+	case *ast.BlockStatement:
+		return stmt.Token
+	case *ast.StructStatement:
+		return stmt.Token
+	case *ast.ForStatement:
+		return stmt.Token
+	case *ast.BreakStatement:
+		return stmt.Token
+	case *ast.ContinueStatement:
+		return stmt.Token
+	case *ast.BadStatement:
+		return stmt.Token
+	}
+	return token.Token{}
+}
+
+func checkBuiltinShadowing(pass *Pass) {
+	builtins := map[string]bool{}
+	for _, name := range evaluator.BuiltinNames() {
+		builtins[name] = true
+	}
+
+	ast.Inspect(pass.Program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			names := []*ast.Identifier{node.Name}
+			if node.Pattern != nil {
+				names = ast.PatternNames(node.Pattern)
+			}
+			for _, name := range names {
+				if name != nil && builtins[name.Value] {
+					pass.Report(diag.TokenSpan(name.Token), "let %s shadows builtin %s", name.Value, name.Value)
+				}
+			}
+		case *ast.FunctionLiteral:
+			for _, param := range node.Parameters {
+				names := []*ast.Identifier{param}
+				if pattern, ok := node.Patterns[param]; ok {
+					names = ast.PatternNames(pattern)
+				}
+				for _, name := range names {
+					if builtins[name.Value] {
+						pass.Report(diag.TokenSpan(name.Token), "parameter %s shadows builtin %s", name.Value, name.Value)
+					}
+				}
+			}
+		case *ast.ForStatement:
+			for _, name := range []*ast.Identifier{node.Key, node.Value} {
+				if name != nil && builtins[name.Value] {
+					pass.Report(diag.TokenSpan(name.Token), "loop variable %s shadows builtin %s", name.Value, name.Value)
+				}
+			}
+		case *ast.MatchArm:
+			for _, name := range ast.PatternNames(node.Pattern) {
+				if builtins[name.Value] {
+					pass.Report(diag.TokenSpan(name.Token), "pattern binding %s shadows builtin %s", name.Value, name.Value)
+				}
+			}
+		}
+		return true
+	})
+}
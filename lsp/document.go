@@ -0,0 +1,180 @@
+// lsp/document.go
+
+package lsp
+
+import (
+	"intInGo/ast"
+	"intInGo/diag"
+	"intInGo/evaluator"
+	"intInGo/lexer"
+	"intInGo/parser"
+	"intInGo/resolver"
+	"intInGo/token"
+	"intInGo/types"
+	"sort"
+	"unicode/utf8"
+)
+
+// an open text document and what the parser made of it
+type document struct {
+	uri         string
+	text        string
+	lines       []int // offset of the first byte of every line
+	tokens      []token.Token
+	braces      map[int]int // offset of { to offset after its }
+	program     *ast.Program
+	diagnostics []diag.Diagnostic
+	index       *index
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text, lines: []int{0}, braces: map[int]int{}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+
+	var open []int
+	l := lexer.New(text)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		d.tokens = append(d.tokens, tok)
+		switch tok.Type {
+		case token.LBRACE:
+			open = append(open, tok.Pos.Offset)
+		case token.RBRACE:
+			if len(open) > 0 {
+				d.braces[open[len(open)-1]] = tok.End.Offset
+				open = open[:len(open)-1]
+			}
+		}
+	}
+
+	p := parser.New(lexer.New(text))
+	d.program = p.ParseProgram()
+	d.diagnostics = p.Diagnostics()
+	d.diagnostics = append(d.diagnostics, resolver.Resolve(d.program, resolver.Options{
+		Globals: evaluator.BuiltinNames(),
+	}).Diagnostics...)
+	d.diagnostics = append(d.diagnostics, types.Check(d.program, types.Options{}).Diagnostics...)
+	d.index = buildIndex(d.program, d.braces, len(text))
+	return d
+}
+
+// lsp position of a byte offset, characters are counted in utf-16 units
+func (d *document) position(offset int) Position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > offset }) - 1
+
+	char := 0
+	for _, r := range d.text[d.lines[line]:offset] {
+		char += utf16Len(r)
+	}
+	return Position{Line: line, Character: char}
+}
+
+// byte offset of an lsp position, clamped to the line it's on
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+
+	offset := d.lines[pos.Line]
+	for char := 0; char < pos.Character && offset < len(d.text); {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		char += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func (d *document) rangeOf(start, end int) Range {
+	if end < start {
+		end = start
+	}
+	return Range{Start: d.position(start), End: d.position(end)}
+}
+
+func (d *document) identRange(ident *ast.Identifier) Range {
+	return d.rangeOf(ident.Token.Pos.Offset, ident.Token.End.Offset)
+}
+
+// offset after the last token of the statement starting at tok, which is
+// the token before the next statement at the same nesting level
+func (d *document) statementEnd(start token.Token) int {
+	i := sort.Search(len(d.tokens), func(i int) bool { return d.tokens[i].Pos.Offset >= start.Pos.Offset })
+	end, depth := start.End.Offset, 0
+
+	for ; i < len(d.tokens); i++ {
+		tok := d.tokens[i]
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET, token.OPTIONAL_LPAREN, token.OPTIONAL_LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+			if depth < 0 {
+				return end
+			}
+			// a struct statement ends with its closing brace
+			if depth == 0 && start.Type == token.STRUCT {
+				return tok.End.Offset
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				return tok.End.Offset
+			}
+		case token.LET, token.RETURN, token.STRUCT, token.FOR, token.BREAK, token.CONTINUE:
+			if depth == 0 && tok.Pos.Offset != start.Pos.Offset {
+				return end
+			}
+		}
+		end = tok.End.Offset
+	}
+	return end
+}
+
+func (d *document) lspDiagnostics() []Diagnostic {
+	diags := []Diagnostic{}
+	for _, dg := range d.diagnostics {
+		message := dg.Message
+		for _, note := range dg.Notes {
+			message += "\nnote: " + note
+		}
+		diags = append(diags, Diagnostic{
+			Range:    d.rangeOf(dg.Span.Start.Offset, dg.Span.End.Offset),
+			Severity: severity(dg.Severity),
+			Code:     dg.Code,
+			Source:   "monkey",
+			Message:  message,
+		})
+	}
+	return diags
+}
+
+func severity(s diag.Severity) int {
+	switch s {
+	case diag.Warning:
+		return SeverityWarning
+	case diag.Info:
+		return SeverityInformation
+	case diag.Hint:
+		return SeverityHint
+	default:
+		return SeverityError
+	}
+}
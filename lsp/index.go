@@ -0,0 +1,394 @@
+// lsp/index.go
+
+package lsp
+
+import (
+	"intInGo/ast"
+	"intInGo/token"
+)
+
+type defKind int
+
+const (
+	letDef defKind = iota
+	paramDef
+	structDef
+	patternDef
+	loopDef
+	recvDef
+)
+
+// a name introduced by a let or struct statement, a function parameter,
+// a pattern of a match arm, a for loop or a select case receiving
+type definition struct {
+	kind defKind
+	name *ast.Identifier
+	let  *ast.LetStatement    // statement of a let binding
+	fn   *ast.FunctionLiteral // function a parameter belongs to
+	decl *ast.StructStatement // statement declaring a struct
+}
+
+// an identifier used as a value, def is nil for builtins and unknown names
+type reference struct {
+	ident *ast.Identifier
+	def   *definition
+}
+
+// names visible in the program or in a function body. blocks of if
+// expressions don't get their own scope, the evaluator doesn't give them
+// their own environment either
+type scope struct {
+	parent     *scope
+	start, end int // byte offsets the scope covers
+	names      map[string]*definition
+	defs       []*definition // in source order
+}
+
+type index struct {
+	scopes []*scope // the program scope comes first
+	defs   []*definition
+	refs   []reference
+}
+
+type indexer struct {
+	idx     *index
+	scope   *scope
+	braces  map[int]int // offset of { to offset after its }
+	pending []pendingBody
+}
+
+type pendingBody struct {
+	scope *scope
+	fn    *ast.FunctionLiteral
+}
+
+// resolve every identifier of the program to the binding it refers to.
+// function bodies only run once the code around them is done, so they
+// are walked after the scope they appear in and see its final bindings
+// like the evaluator's environments do
+func buildIndex(program *ast.Program, braces map[int]int, size int) *index {
+	ix := &indexer{idx: &index{}, braces: braces}
+	ix.walk(ix.newScope(nil, 0, size), program.Statements)
+	return ix.idx
+}
+
+func (ix *indexer) newScope(parent *scope, start, end int) *scope {
+	s := &scope{parent: parent, start: start, end: end, names: map[string]*definition{}}
+	ix.idx.scopes = append(ix.idx.scopes, s)
+	return s
+}
+
+func (ix *indexer) walk(s *scope, stmts []ast.Statement) {
+	outerScope, outerPending := ix.scope, ix.pending
+	ix.scope, ix.pending = s, nil
+
+	for _, stmt := range stmts {
+		ix.statement(stmt)
+	}
+
+	// bodies may queue more bodies of their own, walk consumes them
+	for _, body := range ix.pending {
+		ix.walk(body.scope, body.fn.Body.Statements)
+	}
+
+	ix.scope, ix.pending = outerScope, outerPending
+}
+
+func (ix *indexer) define(d *definition) {
+	ix.scope.names[d.name.Value] = d
+	ix.scope.defs = append(ix.scope.defs, d)
+	ix.idx.defs = append(ix.idx.defs, d)
+}
+
+func (ix *indexer) refer(ident *ast.Identifier) {
+	ref := reference{ident: ident}
+	for s := ix.scope; s != nil; s = s.parent {
+		if d, ok := s.names[ident.Value]; ok {
+			ref.def = d
+			break
+		}
+	}
+	ix.idx.refs = append(ix.idx.refs, ref)
+}
+
+func (ix *indexer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		// the value is evaluated before the name is bound
+		ix.expr(stmt.Value)
+		if stmt.Name != nil {
+			ix.define(&definition{kind: letDef, name: stmt.Name, let: stmt})
+		}
+		if stmt.Pattern != nil {
+			for _, name := range ast.PatternNames(stmt.Pattern) {
+				ix.define(&definition{kind: patternDef, name: name})
+			}
+		}
+	case *ast.ReturnStatement:
+		ix.expr(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		ix.expr(stmt.Expression)
+	case *ast.BlockStatement:
+		ix.block(stmt)
+	case *ast.StructStatement:
+		if stmt.Name != nil {
+			ix.define(&definition{kind: structDef, name: stmt.Name, decl: stmt})
+		}
+	case *ast.ForStatement:
+		// the loop variables are set in the scope the loop is in
+		ix.expr(stmt.Iterable)
+		if stmt.Key != nil {
+			ix.define(&definition{kind: loopDef, name: stmt.Key})
+		}
+		ix.define(&definition{kind: loopDef, name: stmt.Value})
+		ix.block(stmt.Body)
+	}
+}
+
+func (ix *indexer) block(block *ast.BlockStatement) {
+	if block == nil {
+		return
+	}
+	for _, stmt := range block.Statements {
+		ix.statement(stmt)
+	}
+}
+
+func (ix *indexer) expr(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		ix.refer(exp)
+	case *ast.PrefixExpression:
+		ix.expr(exp.Right)
+	case *ast.InfixExpression:
+		ix.expr(exp.Left)
+		ix.expr(exp.Right)
+	case *ast.IfExpression:
+		ix.expr(exp.Condition)
+		ix.block(exp.Consequence)
+		ix.block(exp.Alternative)
+	case *ast.FunctionLiteral:
+		ix.function(exp)
+	case *ast.CallExpression:
+		ix.expr(exp.Function)
+		for _, arg := range exp.Arguments {
+			ix.expr(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			ix.expr(el)
+		}
+	case *ast.HashLiteral:
+		for _, key := range exp.Keys {
+			ix.expr(key)
+			ix.expr(exp.Pairs[key])
+		}
+	case *ast.IndexExpression:
+		ix.expr(exp.Left)
+		ix.expr(exp.Index)
+	case *ast.SliceExpression:
+		ix.expr(exp.Left)
+		ix.expr(exp.Start)
+		ix.expr(exp.End)
+	case *ast.MemberExpression:
+		ix.expr(exp.Object)
+	case *ast.AssignExpression:
+		ix.expr(exp.Target)
+		ix.expr(exp.Value)
+	case *ast.YieldExpression:
+		ix.expr(exp.Value)
+	case *ast.SelectExpression:
+		ix.selectCases(exp)
+	case *ast.MatchExpression:
+		ix.expr(exp.Subject)
+		ix.match(exp)
+	}
+}
+
+// every arm gets a scope of its own, from its pattern to the next arm
+func (ix *indexer) match(exp *ast.MatchExpression) {
+	if len(exp.Arms) == 0 {
+		return
+	}
+
+	// the arms end with the brace opened right before the first pattern
+	first := patternStart(exp.Arms[0].Pattern)
+	end := ix.scope.end
+	lbrace := -1
+	for open, close := range ix.braces {
+		if open > exp.Token.Pos.Offset && open < first && close > first && open > lbrace {
+			lbrace, end = open, close
+		}
+	}
+
+	outer := ix.scope
+	for i, arm := range exp.Arms {
+		armEnd := end
+		if i+1 < len(exp.Arms) {
+			armEnd = patternStart(exp.Arms[i+1].Pattern)
+		}
+		ix.scope = ix.newScope(outer, patternStart(arm.Pattern), armEnd)
+		for _, name := range ast.PatternNames(arm.Pattern) {
+			ix.define(&definition{kind: patternDef, name: name})
+		}
+		ix.expr(arm.Guard)
+		ix.expr(arm.Body)
+	}
+	ix.scope = outer
+}
+
+// like the arms of a match, each case of a select is a scope of its own
+// that lasts until the next case
+func (ix *indexer) selectCases(exp *ast.SelectExpression) {
+	for _, c := range exp.Cases {
+		ix.expr(c.Channel)
+		ix.expr(c.Value)
+	}
+	if len(exp.Cases) == 0 {
+		return
+	}
+
+	start := func(c *ast.SelectCase) int {
+		if c.Name != nil {
+			return c.Name.Token.Pos.Offset
+		}
+		return c.Op.Pos.Offset
+	}
+
+	// the cases end with the brace right after the select keyword
+	end, lbrace := ix.scope.end, -1
+	for open, close := range ix.braces {
+		if open > exp.Token.Pos.Offset && (lbrace < 0 || open < lbrace) {
+			lbrace, end = open, close
+		}
+	}
+
+	outer := ix.scope
+	for i, c := range exp.Cases {
+		caseEnd := end
+		if i+1 < len(exp.Cases) {
+			caseEnd = start(exp.Cases[i+1])
+		}
+		ix.scope = ix.newScope(outer, start(c), caseEnd)
+		if c.Name != nil {
+			ix.define(&definition{kind: recvDef, name: c.Name})
+		}
+		ix.expr(c.Body)
+	}
+	ix.scope = outer
+}
+
+func patternStart(pattern ast.Pattern) int {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		if prefix, ok := pattern.Value.(*ast.PrefixExpression); ok {
+			return prefix.Token.Pos.Offset
+		}
+		return tokenOf(pattern.Value).Pos.Offset
+	case *ast.WildcardPattern:
+		return pattern.Token.Pos.Offset
+	case *ast.BindingPattern:
+		return pattern.Name.Token.Pos.Offset
+	case *ast.ArrayPattern:
+		return pattern.Token.Pos.Offset
+	case *ast.HashPattern:
+		return pattern.Token.Pos.Offset
+	}
+	return 0
+}
+
+// token of a literal
+func tokenOf(exp ast.Expression) token.Token {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return exp.Token
+	case *ast.DecimalLiteral:
+		return exp.Token
+	case *ast.StringLiteral:
+		return exp.Token
+	case *ast.Boolean:
+		return exp.Token
+	case *ast.NullLiteral:
+		return exp.Token
+	}
+	return token.Token{}
+}
+
+func (ix *indexer) function(fn *ast.FunctionLiteral) {
+	end := ix.scope.end
+	if fn.Body != nil {
+		if close, ok := ix.braces[fn.Body.Token.Pos.Offset]; ok {
+			end = close
+		}
+	}
+
+	s := ix.newScope(ix.scope, fn.Token.Pos.Offset, end)
+	outer := ix.scope
+	ix.scope = s
+	for _, param := range fn.Parameters {
+		if def, ok := fn.Defaults[param]; ok {
+			ix.expr(def)
+		}
+		if pattern, ok := fn.Patterns[param]; ok {
+			for _, name := range ast.PatternNames(pattern) {
+				ix.define(&definition{kind: paramDef, name: name, fn: fn})
+			}
+			continue
+		}
+		ix.define(&definition{kind: paramDef, name: param, fn: fn})
+	}
+	if fn.Rest != nil {
+		ix.define(&definition{kind: paramDef, name: fn.Rest, fn: fn})
+	}
+	ix.scope = outer
+
+	if fn.Body != nil {
+		ix.pending = append(ix.pending, pendingBody{scope: s, fn: fn})
+	}
+}
+
+// the definition or reference whose identifier covers offset
+func (idx *index) lookup(offset int) (*ast.Identifier, *definition, bool) {
+	for _, d := range idx.defs {
+		if covers(d.name, offset) {
+			return d.name, d, true
+		}
+	}
+	for _, ref := range idx.refs {
+		if covers(ref.ident, offset) {
+			return ref.ident, ref.def, true
+		}
+	}
+	return nil, nil, false
+}
+
+// the cursor right after an identifier still counts as on it
+func covers(ident *ast.Identifier, offset int) bool {
+	return ident.Token.Pos.Offset <= offset && offset <= ident.Token.End.Offset
+}
+
+// definitions visible at offset, innermost first. names of the scope the
+// offset is in are only visible once they're defined, names of enclosing
+// scopes are all visible since the function body runs later
+func (idx *index) visible(offset int) []*definition {
+	var inner *scope
+	for _, s := range idx.scopes {
+		if s.start <= offset && offset <= s.end && (inner == nil || s.start >= inner.start) {
+			inner = s
+		}
+	}
+
+	seen := map[string]bool{}
+	var defs []*definition
+	for s := inner; s != nil; s = s.parent {
+		for i := len(s.defs) - 1; i >= 0; i-- {
+			d := s.defs[i]
+			if seen[d.name.Value] || (s == inner && d.name.Token.End.Offset > offset) {
+				continue
+			}
+			seen[d.name.Value] = true
+			defs = append(defs, d)
+		}
+	}
+	return defs
+}
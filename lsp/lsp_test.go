@@ -0,0 +1,438 @@
+// lsp/lsp_test.go
+
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const uri = "file:///test.mk"
+
+const source = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = add(1, 2);
+le`
+
+// run the server over a session of requests and collect what it wrote
+func session(t *testing.T, msgs ...interface{}) []map[string]interface{} {
+	var in bytes.Buffer
+	for _, msg := range msgs {
+		if err := writeMessage(&in, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := NewServer(&in, &out).Run(); err != nil {
+		t.Fatalf("server failed: %s", err)
+	}
+
+	var replies []map[string]interface{}
+	r := bufio.NewReader(&out)
+	for {
+		data, err := readMessage(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reply map[string]interface{}
+		if err := json.Unmarshal(data, &reply); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, reply)
+	}
+	return replies
+}
+
+func call(id int, method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+}
+
+func notify(method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+}
+
+func open(text string) map[string]interface{} {
+	return notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "version": 1, "text": text},
+	})
+}
+
+func at(id int, method string, line, char int) map[string]interface{} {
+	return call(id, method, map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": line, "character": char},
+	})
+}
+
+// reply to the request with the given id
+func result(t *testing.T, replies []map[string]interface{}, id int) interface{} {
+	for _, r := range replies {
+		if r["id"] == float64(id) {
+			if r["error"] != nil {
+				t.Fatalf("request %d failed: %v", id, r["error"])
+			}
+			return r["result"]
+		}
+	}
+	t.Fatalf("no reply to request %d", id)
+	return nil
+}
+
+func compact(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func TestInitializeAndShutdown(t *testing.T) {
+	replies := session(t,
+		call(1, "initialize", map[string]interface{}{}),
+		notify("initialized", map[string]interface{}{}),
+		call(2, "textDocument/formatting", map[string]interface{}{}),
+		call(3, "shutdown", nil),
+		notify("exit", nil),
+	)
+
+	caps := compact(result(t, replies, 1))
+	for _, want := range []string{`"textDocumentSync":1`, `"definitionProvider":true`, `"hoverProvider":true`, `"documentSymbolProvider":true`} {
+		if !strings.Contains(caps, want) {
+			t.Errorf("capabilities missing %s. got=%s", want, caps)
+		}
+	}
+
+	if err := replies[1]["error"].(map[string]interface{}); err["code"] != float64(methodNotFound) {
+		t.Errorf("expected method not found error. got=%v", err)
+	}
+	if res, ok := replies[2]["result"]; !ok || res != nil {
+		t.Errorf("shutdown should reply with null. got=%v", replies[2])
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	var in, out bytes.Buffer
+	writeMessage(&in, notify("exit", nil))
+	if err := NewServer(&in, &out).Run(); err == nil {
+		t.Errorf("expected an error exiting without shutdown")
+	}
+}
+
+func TestPublishDiagnostics(t *testing.T) {
+	replies := session(t,
+		open("let x 5;"),
+		notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+			"contentChanges": []interface{}{map[string]interface{}{"text": "let x = 5;"}},
+		}),
+	)
+
+	if len(replies) != 2 {
+		t.Fatalf("expected 2 notifications. got=%d", len(replies))
+	}
+
+	first := compact(replies[0]["params"])
+	want := `{"diagnostics":[{"code":"E0100","message":"expected next token to be =, got INT","range":{"end":{"character":7,"line":0},"start":{"character":6,"line":0}},"severity":1,"source":"monkey"}],"uri":"file:///test.mk"}`
+	if first != want {
+		t.Errorf("wrong diagnostics.\nwant=%s\ngot= %s", want, first)
+	}
+
+	if second := compact(replies[1]["params"]); second != `{"diagnostics":[],"uri":"file:///test.mk"}` {
+		t.Errorf("diagnostics not cleared after fix. got=%s", second)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	tests := []struct {
+		line, char int
+		expected   string
+	}{
+		{1, 12, `{"end":{"character":14,"line":0},"start":{"character":13,"line":0}}`}, // a
+		{2, 3, `{"end":{"character":9,"line":1},"start":{"character":6,"line":1}}`},    // sum
+		{4, 9, `{"end":{"character":7,"line":0},"start":{"character":4,"line":0}}`},    // add
+		{0, 5, `{"end":{"character":7,"line":0},"start":{"character":4,"line":0}}`},    // add itself
+		{4, 1, "null"}, // let keyword
+	}
+
+	msgs := []interface{}{open(source)}
+	for i, tt := range tests {
+		msgs = append(msgs, at(i+1, "textDocument/definition", tt.line, tt.char))
+	}
+	replies := session(t, msgs...)
+
+	for i, tt := range tests {
+		res := result(t, replies, i+1)
+		got := "null"
+		if res != nil {
+			got = compact(res.(map[string]interface{})["range"])
+		}
+		if got != tt.expected {
+			t.Errorf("tests[%d] - wrong definition. want=%s, got=%s", i, tt.expected, got)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	tests := []struct {
+		line, char int
+		expected   string
+	}{
+		{4, 9, "let add = fn(a, b)"},
+		{2, 3, "let sum = a + b"},
+		{1, 12, "(parameter) a of fn(a, b)"},
+		{4, 4, "let x = add(1, 2)"},
+	}
+
+	msgs := []interface{}{open(source + "\nlen(x)")}
+	for i, tt := range tests {
+		msgs = append(msgs, at(i+1, "textDocument/hover", tt.line, tt.char))
+	}
+	msgs = append(msgs, at(len(tests)+1, "textDocument/hover", 6, 1))
+	replies := session(t, msgs...)
+
+	for i, tt := range tests {
+		res := result(t, replies, i+1).(map[string]interface{})
+		got := res["contents"].(map[string]interface{})["value"]
+		if want := "```monkey\n" + tt.expected + "\n```"; got != want {
+			t.Errorf("tests[%d] - wrong hover. want=%q, got=%q", i, want, got)
+		}
+	}
+
+	res := compact(result(t, replies, len(tests)+1))
+	if !strings.Contains(res, "(builtin) len") {
+		t.Errorf("wrong hover for builtin. got=%s", res)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		line, char int
+		include    []string
+		exclude    []string
+	}{
+		// inside add, sum is not bound yet
+		{1, 2, []string{"a", "b", "add", "x", "len", "puts"}, []string{"sum"}},
+		{2, 2, []string{"a", "b", "sum", "add"}, nil},
+		// at the top level the function's names are out of scope
+		{5, 2, []string{"add", "x", "first"}, []string{"a", "b", "sum"}},
+	}
+
+	msgs := []interface{}{open(source)}
+	for i, tt := range tests {
+		msgs = append(msgs, at(i+1, "textDocument/completion", tt.line, tt.char))
+	}
+	replies := session(t, msgs...)
+
+	for i, tt := range tests {
+		labels := map[string]bool{}
+		for _, item := range result(t, replies, i+1).([]interface{}) {
+			labels[item.(map[string]interface{})["label"].(string)] = true
+		}
+		for _, name := range tt.include {
+			if !labels[name] {
+				t.Errorf("tests[%d] - completion missing %q", i, name)
+			}
+		}
+		for _, name := range tt.exclude {
+			if labels[name] {
+				t.Errorf("tests[%d] - completion should not offer %q", i, name)
+			}
+		}
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	replies := session(t,
+		open(source),
+		call(1, "textDocument/documentSymbol", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+		}),
+	)
+
+	var got []string
+	var walk func(syms []interface{}, prefix string)
+	walk = func(syms []interface{}, prefix string) {
+		for _, s := range syms {
+			sym := s.(map[string]interface{})
+			r := sym["range"].(map[string]interface{})
+			start := r["start"].(map[string]interface{})
+			end := r["end"].(map[string]interface{})
+			got = append(got, fmt.Sprintf("%s%s %v %v:%v-%v:%v", prefix, sym["name"], sym["kind"],
+				start["line"], start["character"], end["line"], end["character"]))
+			if children, ok := sym["children"].([]interface{}); ok {
+				walk(children, prefix+"  ")
+			}
+		}
+	}
+	walk(result(t, replies, 1).([]interface{}), "")
+
+	expected := []string{
+		"add 12 0:0-3:2",
+		"  sum 13 1:2-1:18",
+		"x 13 4:0-4:18",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong symbols.\nwant:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestStructs(t *testing.T) {
+	replies := session(t,
+		open("struct Point { x, y }\nlet p = Point(1, 2);\np.x"),
+		call(1, "textDocument/documentSymbol", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+		}),
+		at(2, "textDocument/hover", 1, 9),
+		at(3, "textDocument/definition", 1, 9),
+	)
+
+	var got []string
+	for _, s := range result(t, replies, 1).([]interface{}) {
+		sym := s.(map[string]interface{})
+		got = append(got, fmt.Sprintf("%s %v", sym["name"], sym["kind"]))
+		children, _ := sym["children"].([]interface{})
+		for _, c := range children {
+			child := c.(map[string]interface{})
+			got = append(got, fmt.Sprintf("  %s %v", child["name"], child["kind"]))
+		}
+	}
+	expected := []string{"Point 23", "  x 8", "  y 8", "p 13"}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong symbols.\nwant:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	hover := compact(result(t, replies, 2))
+	if !strings.Contains(hover, "struct Point { x, y }") {
+		t.Errorf("wrong hover for struct. got=%s", hover)
+	}
+
+	def := compact(result(t, replies, 3))
+	if !strings.Contains(def, `"start":{"character":7,"line":0}`) {
+		t.Errorf("wrong definition of struct. got=%s", def)
+	}
+}
+
+func TestMatchBindings(t *testing.T) {
+	src := "let r = match ([1]) {\n  [h, ...t] => h,\n  n => n\n};\nr"
+	replies := session(t,
+		open(src),
+		at(1, "textDocument/hover", 1, 15),
+		at(2, "textDocument/completion", 1, 15),
+		at(3, "textDocument/completion", 4, 1),
+	)
+
+	hover := compact(result(t, replies, 1))
+	if !strings.Contains(hover, "(pattern) h") {
+		t.Errorf("wrong hover for pattern binding. got=%s", hover)
+	}
+
+	tests := []struct {
+		id      int
+		include []string
+		exclude []string
+	}{
+		{2, []string{"h", "t"}, []string{"n"}},
+		{3, []string{"r"}, []string{"h", "t", "n"}},
+	}
+	for _, tt := range tests {
+		labels := map[string]bool{}
+		for _, item := range result(t, replies, tt.id).([]interface{}) {
+			labels[item.(map[string]interface{})["label"].(string)] = true
+		}
+		for _, name := range tt.include {
+			if !labels[name] {
+				t.Errorf("request %d - completion missing %q", tt.id, name)
+			}
+		}
+		for _, name := range tt.exclude {
+			if labels[name] {
+				t.Errorf("request %d - completion should not offer %q", tt.id, name)
+			}
+		}
+	}
+}
+
+func TestForLoops(t *testing.T) {
+	src := "for (i, x in [1]) {\n  let y = x;\n}\ny"
+	replies := session(t,
+		open(src),
+		at(1, "textDocument/hover", 1, 10),
+		at(2, "textDocument/definition", 3, 0),
+		call(3, "textDocument/documentSymbol", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+		}),
+	)
+
+	hover := compact(result(t, replies, 1))
+	if !strings.Contains(hover, "(loop variable) x") {
+		t.Errorf("wrong hover for loop variable. got=%s", hover)
+	}
+
+	def := compact(result(t, replies, 2))
+	if !strings.Contains(def, `"start":{"character":6,"line":1}`) {
+		t.Errorf("let in loop body should be the definition. got=%s", def)
+	}
+
+	symbols := compact(result(t, replies, 3))
+	if !strings.Contains(symbols, `"name":"y"`) {
+		t.Errorf("lets in loop bodies should be symbols. got=%s", symbols)
+	}
+}
+
+func TestSelectCases(t *testing.T) {
+	src := "let c = channel(1);\nselect {\n  v = recv(c) => v,\n  _ => 0\n}"
+	replies := session(t,
+		open(src),
+		at(1, "textDocument/hover", 2, 17),
+		at(2, "textDocument/completion", 2, 17),
+		at(3, "textDocument/completion", 3, 7),
+	)
+
+	hover := compact(result(t, replies, 1))
+	if !strings.Contains(hover, "(received) v") {
+		t.Errorf("wrong hover for received value. got=%s", hover)
+	}
+
+	for id, want := range map[int]bool{2: true, 3: false} {
+		offered := false
+		for _, item := range result(t, replies, id).([]interface{}) {
+			if item.(map[string]interface{})["label"] == "v" {
+				offered = true
+			}
+		}
+		if offered != want {
+			t.Errorf("request %d - completion of v: got=%t, want=%t", id, offered, want)
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	doc := newDocument(uri, "let s = \"héllo 😀\";\nx")
+
+	tests := []struct {
+		offset int
+		pos    Position
+	}{
+		{0, Position{0, 0}},
+		{10, Position{0, 10}}, // after é, two bytes but one unit
+		{20, Position{0, 17}}, // after the emoji, four bytes but two units
+		{23, Position{1, 0}},
+	}
+
+	for _, tt := range tests {
+		if got := doc.position(tt.offset); got != tt.pos {
+			t.Errorf("position(%d) wrong. want=%+v, got=%+v", tt.offset, tt.pos, got)
+		}
+		if got := doc.offset(tt.pos); got != tt.offset {
+			t.Errorf("offset(%+v) wrong. want=%d, got=%d", tt.pos, tt.offset, got)
+		}
+	}
+}
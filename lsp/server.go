@@ -0,0 +1,401 @@
+// lsp/server.go
+
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"intInGo/ast"
+	"intInGo/evaluator"
+	"intInGo/format"
+	"io"
+	"sort"
+	"strings"
+)
+
+// a language server talking json-rpc over a pair of streams
+type Server struct {
+	in       *bufio.Reader
+	out      io.Writer
+	docs     map[string]*document
+	shutdown bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{in: bufio.NewReader(in), out: out, docs: map[string]*document{}}
+}
+
+// serve requests until the client sends exit or closes the connection.
+// exiting without a shutdown request first is an error
+func (s *Server) Run() error {
+	for {
+		data, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(data, &req); err != nil {
+			s.reply(json.RawMessage("null"), nil, errorf(parseError, "%s", err))
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+
+		result, err := s.handle(req)
+		if req.ID == nil {
+			continue // notifications get no response
+		}
+		if err := s.reply(req.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) reply(id json.RawMessage, result interface{}, err error) error {
+	resp := response{JSONRPC: "2.0", ID: id}
+	if err != nil {
+		var rerr *rpcError
+		if !errors.As(err, &rerr) {
+			rerr = errorf(internalError, "%s", err)
+		}
+		resp.Error = rerr
+	} else {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		resp.Result = data
+	}
+	return writeMessage(s.out, resp)
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return writeMessage(s.out, notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) handle(req request) (interface{}, error) {
+	switch req.Method {
+	case "initialize":
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:       syncFull,
+				DefinitionProvider:     true,
+				HoverProvider:          true,
+				CompletionProvider:     &CompletionOptions{},
+				DocumentSymbolProvider: true,
+			},
+			ServerInfo: ServerInfo{Name: "monkey"},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := decode(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := decode(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			return nil, s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := decode(req.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+	case "textDocument/definition":
+		return s.withPosition(req, s.definition)
+	case "textDocument/hover":
+		return s.withPosition(req, s.hover)
+	case "textDocument/completion":
+		return s.withPosition(req, s.completion)
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := decode(req.Params, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return doc.symbols(doc.program.Statements), nil
+	}
+
+	if req.ID == nil {
+		return nil, nil // unknown notifications are ignored
+	}
+	return nil, errorf(methodNotFound, "method not found: %s", req.Method)
+}
+
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return errorf(invalidParams, "invalid params: %s", err)
+	}
+	return nil
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, errorf(invalidParams, "unknown document: %s", uri)
+	}
+	return doc, nil
+}
+
+func (s *Server) withPosition(req request, fn func(*document, int) interface{}) (interface{}, error) {
+	var params TextDocumentPositionParams
+	if err := decode(req.Params, &params); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return fn(doc, doc.offset(params.Position)), nil
+}
+
+// reparse a document and publish its diagnostics
+func (s *Server) update(uri, text string) error {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: doc.lspDiagnostics(),
+	})
+}
+
+func (s *Server) definition(doc *document, offset int) interface{} {
+	_, def, ok := doc.index.lookup(offset)
+	if !ok || def == nil {
+		return nil
+	}
+	return Location{URI: doc.uri, Range: doc.identRange(def.name)}
+}
+
+func (s *Server) hover(doc *document, offset int) interface{} {
+	ident, def, ok := doc.index.lookup(offset)
+	if !ok {
+		return nil
+	}
+
+	var text string
+	switch {
+	case def != nil && def.kind == letDef:
+		text = "let " + def.name.Value
+		if def.let.Type != nil {
+			text += ": " + def.let.Type.String()
+		}
+		text += " = " + summary(def.let.Value)
+	case def != nil && def.kind == structDef:
+		text = def.decl.String()
+	case def != nil && def.kind == patternDef:
+		text = "(pattern) " + def.name.Value
+	case def != nil && def.kind == loopDef:
+		text = "(loop variable) " + def.name.Value
+	case def != nil && def.kind == recvDef:
+		text = "(received) " + def.name.Value
+	case def != nil && def.kind == paramDef:
+		text = "(parameter) " + def.name.Value + " of " + signature(def.fn)
+	case isBuiltin(ident.Value):
+		text = "(builtin) " + ident.Value
+	default:
+		return nil
+	}
+
+	return Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```monkey\n" + text + "\n```"},
+		Range:    doc.identRange(ident),
+	}
+}
+
+// longest hover text shown for the value of a let binding
+const maxSummary = 60
+
+func summary(exp ast.Expression) string {
+	if exp == nil {
+		return ""
+	}
+	if fn, ok := exp.(*ast.FunctionLiteral); ok {
+		return signature(fn)
+	}
+
+	s := format.Expression(exp, format.Options{})
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i] + " ..."
+	}
+	if len(s) > maxSummary {
+		s = s[:maxSummary] + "..."
+	}
+	return s
+}
+
+func signature(fn *ast.FunctionLiteral) string {
+	params := make([]string, 0, len(fn.Parameters)+1)
+	for _, p := range fn.Parameters {
+		s := p.Value
+		if typ, ok := fn.Types[p]; ok {
+			s += ": " + typ.String()
+		}
+		if def, ok := fn.Defaults[p]; ok {
+			s += " = " + format.Expression(def, format.Options{})
+		}
+		params = append(params, s)
+	}
+	if fn.Rest != nil {
+		s := "..." + fn.Rest.Value
+		if typ, ok := fn.Types[fn.Rest]; ok {
+			s += ": " + typ.String()
+		}
+		params = append(params, s)
+	}
+
+	out := "fn(" + strings.Join(params, ", ") + ")"
+	if fn.ReturnType != nil {
+		out += ": " + fn.ReturnType.String()
+	}
+	return out
+}
+
+func isBuiltin(name string) bool {
+	for _, b := range evaluator.BuiltinNames() {
+		if b == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) completion(doc *document, offset int) interface{} {
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+
+	defs := doc.index.visible(offset)
+	sort.SliceStable(defs, func(i, j int) bool { return defs[i].name.Value < defs[j].name.Value })
+	for _, d := range defs {
+		seen[d.name.Value] = true
+		item := CompletionItem{Label: d.name.Value, Kind: CompletionVariable}
+		switch d.kind {
+		case letDef:
+			if fn, ok := d.let.Value.(*ast.FunctionLiteral); ok {
+				item.Kind = CompletionFunction
+				item.Detail = signature(fn)
+			}
+		case structDef:
+			item.Kind = CompletionStruct
+			item.Detail = "struct"
+		case patternDef:
+			item.Detail = "pattern"
+		case loopDef:
+			item.Detail = "loop variable"
+		case recvDef:
+			item.Detail = "received"
+		default:
+			item.Detail = "parameter"
+		}
+		items = append(items, item)
+	}
+
+	for _, name := range evaluator.BuiltinNames() {
+		if !seen[name] {
+			items = append(items, CompletionItem{Label: name, Kind: CompletionFunction, Detail: "builtin"})
+		}
+	}
+	return items
+}
+
+// let statements of a program or function body, functions bound by a
+// let list the lets of their body as children
+func (d *document) symbols(stmts []ast.Statement) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			if stmt.Pattern != nil {
+				// every name of a destructuring let is a variable of its own
+				for _, name := range ast.PatternNames(stmt.Pattern) {
+					symbols = append(symbols, DocumentSymbol{
+						Name:           name.Value,
+						Kind:           SymbolVariable,
+						Range:          d.rangeOf(stmt.Token.Pos.Offset, d.statementEnd(stmt.Token)),
+						SelectionRange: d.identRange(name),
+					})
+				}
+				continue
+			}
+			if stmt.Name == nil {
+				continue
+			}
+			sym := DocumentSymbol{
+				Name:           stmt.Name.Value,
+				Kind:           SymbolVariable,
+				Range:          d.rangeOf(stmt.Token.Pos.Offset, d.statementEnd(stmt.Token)),
+				SelectionRange: d.identRange(stmt.Name),
+			}
+			if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+				sym.Kind = SymbolFunction
+				sym.Detail = signature(fn)
+				if fn.Body != nil {
+					sym.Children = d.symbols(fn.Body.Statements)
+				}
+			}
+			symbols = append(symbols, sym)
+		case *ast.StructStatement:
+			if stmt.Name == nil {
+				continue
+			}
+			sym := DocumentSymbol{
+				Name:           stmt.Name.Value,
+				Kind:           SymbolStruct,
+				Range:          d.rangeOf(stmt.Token.Pos.Offset, d.statementEnd(stmt.Token)),
+				SelectionRange: d.identRange(stmt.Name),
+				Children:       []DocumentSymbol{},
+			}
+			for _, field := range stmt.Fields {
+				sym.Children = append(sym.Children, DocumentSymbol{
+					Name:           field.Value,
+					Kind:           SymbolField,
+					Range:          d.identRange(field),
+					SelectionRange: d.identRange(field),
+				})
+			}
+			symbols = append(symbols, sym)
+		case *ast.ForStatement:
+			// lets inside loop bodies bind in the enclosing scope
+			if stmt.Body != nil {
+				symbols = append(symbols, d.symbols(stmt.Body.Statements)...)
+			}
+		case *ast.ExpressionStatement:
+			// lets inside if blocks still bind in the enclosing scope
+			if ifExp, ok := stmt.Expression.(*ast.IfExpression); ok {
+				for _, block := range []*ast.BlockStatement{ifExp.Consequence, ifExp.Alternative} {
+					if block != nil {
+						symbols = append(symbols, d.symbols(block.Statements)...)
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
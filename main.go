@@ -3,6 +3,7 @@
 package main
 
 import (
+  "flag"
   "fmt"
   "os"
   "os/user"
@@ -10,11 +11,27 @@ import (
 )
 
 func main() {
+  if len(os.Args) > 1 {
+    switch os.Args[1] {
+    case "fmt":
+      os.Exit(runFmt(os.Args[2:]))
+    case "lint":
+      os.Exit(runLint(os.Args[2:]))
+    case "lsp":
+      os.Exit(runLSP(os.Args[2:]))
+    }
+  }
+
+  // the file builtins are off unless a directory is given for them
+  root := flag.String("root", "", "directory the file builtins are confined to (default disabled)")
+  flag.Parse()
+
   user, err := user.Current()
   if err != nil {
     panic(err)
   }
   fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
   fmt.Printf("Type in any command\n")
-  repl.Start(os.Stdin, os.Stdout)
+
+  repl.Start(os.Stdin, os.Stdout, *root)
 }
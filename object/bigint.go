@@ -0,0 +1,44 @@
+package object
+
+import (
+	"hash/fnv"
+	"math/big"
+)
+
+// integer too large for an Integer. arithmetic on integers promotes its
+// result to one when it overflows int64, and turns results that fit again
+// back into Integers, so a value has only one representation
+type BigInt struct {
+	Value *big.Int
+}
+
+func (bi *BigInt) Type() ObjectType { return BIGINT_OBJ }
+func (bi *BigInt) Inspect() string  { return bi.Value.String() }
+func (bi *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	if bi.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+	h.Write(bi.Value.Bytes())
+	return HashKey{Type: bi.Type(), Value: h.Sum64()}
+}
+
+// an Integer if value fits in one, a BigInt otherwise
+func NewBigInt(value *big.Int) Object {
+	if value.IsInt64() {
+		return &Integer{Value: value.Int64()}
+	}
+	return &BigInt{Value: value}
+}
+
+// value of an Integer or BigInt, false for other objects. the big.Int of a
+// BigInt is returned as is and must not be modified
+func BigValue(obj Object) (*big.Int, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value), true
+	case *BigInt:
+		return obj.Value, true
+	}
+	return nil, false
+}
@@ -0,0 +1,137 @@
+package object
+
+import (
+	"fmt"
+	"sync"
+)
+
+// result of a function running on a goroutine of its own
+type Task struct {
+	done   chan struct{} // closed once result is set
+	result Object
+}
+
+func (t *Task) Type() ObjectType { return TASK_OBJ }
+func (t *Task) Inspect() string {
+	select {
+	case <-t.done:
+		return "task(done)"
+	default:
+		return "task(running)"
+	}
+}
+
+// run fn on a new goroutine
+func NewTask(fn func() Object) *Task {
+	t := &Task{done: make(chan struct{})}
+	go func() {
+		defer close(t.done)
+		t.result = fn()
+	}()
+	return t
+}
+
+// wait for the task to finish and get what it returned
+func (t *Task) Await() Object {
+	<-t.done
+	return t.result
+}
+
+// channel passing values between tasks. sends block until a receiver
+// takes the value, or until there is room in the buffer for buffered
+// channels
+type Channel struct {
+	C chan Object
+
+	// closed when the channel is closed, wakes blocked senders
+	done    chan struct{}
+	closeMu sync.Mutex
+
+	// held for reading while sending, so C is only closed once pending
+	// sends have given up instead of making them panic
+	mu sync.RWMutex
+}
+
+func NewChannel(capacity int) *Channel {
+	return &Channel{C: make(chan Object, capacity), done: make(chan struct{})}
+}
+
+func (c *Channel) Type() ObjectType { return CHANNEL_OBJ }
+func (c *Channel) Inspect() string {
+	return fmt.Sprintf("channel(%d/%d)", len(c.C), cap(c.C))
+}
+
+// send a value, false if the channel is closed, also while waiting
+func (c *Channel) Send(value Object) bool {
+	if !c.LockSend() {
+		return false
+	}
+	defer c.UnlockSend()
+	select {
+	case c.C <- value:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// keep the channel from being closed while sending on C directly, false
+// if it already is closed. sends must also wait on Done and give up
+// once it is closed
+func (c *Channel) LockSend() bool {
+	c.mu.RLock()
+	select {
+	case <-c.done:
+		c.mu.RUnlock()
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *Channel) UnlockSend() {
+	c.mu.RUnlock()
+}
+
+// closed once the channel is closed
+func (c *Channel) Done() <-chan struct{} {
+	return c.done
+}
+
+// receive a value, ok is false once the channel is closed and drained
+func (c *Channel) Recv() (value Object, ok bool) {
+	value, ok = <-c.C
+	return value, ok
+}
+
+// close the channel, false if it already was. blocked senders are woken
+// and fail before C is closed
+func (c *Channel) Close() bool {
+	c.closeMu.Lock()
+	select {
+	case <-c.done:
+		c.closeMu.Unlock()
+		return false
+	default:
+	}
+	close(c.done)
+	c.closeMu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	close(c.C)
+	return true
+}
+
+// values received until the channel is closed, with their index
+func (c *Channel) Iter() Iterator {
+	i := int64(0)
+	return &funcIterator{next: func() (Object, Object, bool) {
+		value, ok := c.Recv()
+		if !ok {
+			return nil, nil, false
+		}
+		i++
+		return &Integer{Value: i - 1}, value, true
+	}}
+}
@@ -0,0 +1,52 @@
+package object
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// state of one interpreter, handed to builtins: the streams a program
+// reads from and writes to and the settings it runs with. interpreters
+// with contexts of their own can run side by side
+type Context struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  *bufio.Reader
+
+	// builtin functions by name, the evaluator's defaults when nil
+	Builtins map[string]*BuiltIn
+
+	// when set, indexing an array or string out of range produces an
+	// error instead of null
+	StrictIndexing bool
+
+	// when set, integer arithmetic whose result doesn't fit in an int64
+	// produces an error instead of a big integer
+	StrictOverflow bool
+
+	// scale of decimal quotients unless their operands have a larger one,
+	// and how they are rounded to it. NewContext starts it at
+	// DefaultDecimalScale; 0 rounds quotients to whole numbers
+	DecimalScale int
+	Rounding     RoundingMode
+
+	// directory the file builtins are confined to, file access is
+	// disabled while it is empty
+	FileRoot string
+}
+
+// digits after the point of decimal quotients in new contexts, so 1d / 3d
+// is 0.3333333333 rather than 0
+const DefaultDecimalScale = 10
+
+func NewContext(in io.Reader, out, errOut io.Writer) *Context {
+	reader, ok := in.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReader(in)
+	}
+	return &Context{Stdout: out, Stderr: errOut, Stdin: reader, DecimalScale: DefaultDecimalScale}
+}
+
+// context used by environments that weren't given one
+var defaultContext = NewContext(os.Stdin, os.Stdout, os.Stderr)
@@ -0,0 +1,234 @@
+package object
+
+import (
+	"hash/fnv"
+	"math/big"
+	"strings"
+)
+
+// exact fixed-point number, Value * 10^-Scale. the scale is the number of
+// digits after the point and is kept by Inspect, so 12.50 stays 12.50
+type Decimal struct {
+	Value *big.Int
+	Scale int
+}
+
+func (d *Decimal) Type() ObjectType { return DECIMAL_OBJ }
+func (d *Decimal) Inspect() string {
+	digits := new(big.Int).Abs(d.Value).String()
+	if len(digits) <= d.Scale {
+		digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+	}
+
+	var out strings.Builder
+	if d.Value.Sign() < 0 {
+		out.WriteByte('-')
+	}
+	out.WriteString(digits[:len(digits)-d.Scale])
+	if d.Scale > 0 {
+		out.WriteByte('.')
+		out.WriteString(digits[len(digits)-d.Scale:])
+	}
+	return out.String()
+}
+
+// equal values have equal keys whatever their scale, 1.5 and 1.50 are the
+// same key. a decimal is never the same key as an integer though
+func (d *Decimal) HashKey() HashKey {
+	value, scale := new(big.Int).Set(d.Value), d.Scale
+	ten, rem := big.NewInt(10), new(big.Int)
+	for scale > 0 {
+		quo, _ := new(big.Int).QuoRem(value, ten, rem)
+		if rem.Sign() != 0 {
+			break
+		}
+		value, scale = quo, scale-1
+	}
+
+	h := fnv.New64a()
+	if value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+	h.Write(value.Bytes())
+	h.Write([]byte{'.', byte(scale), byte(scale >> 8)})
+	return HashKey{Type: d.Type(), Value: h.Sum64()}
+}
+
+// how a decimal is rounded when it loses digits
+type RoundingMode int
+
+const (
+	RoundHalfEven RoundingMode = iota // to nearest, ties to even
+	RoundHalfUp                       // to nearest, ties away from zero
+	RoundHalfDown                     // to nearest, ties towards zero
+	RoundUp                           // away from zero
+	RoundDown                         // towards zero
+	RoundCeiling                      // towards positive infinity
+	RoundFloor                        // towards negative infinity
+)
+
+var roundingModes = map[string]RoundingMode{
+	"half_even": RoundHalfEven,
+	"half_up":   RoundHalfUp,
+	"half_down": RoundHalfDown,
+	"up":        RoundUp,
+	"down":      RoundDown,
+	"ceiling":   RoundCeiling,
+	"floor":     RoundFloor,
+}
+
+// rounding mode by the name programs use for it, like half_even
+func LookupRoundingMode(name string) (RoundingMode, bool) {
+	mode, ok := roundingModes[name]
+	return mode, ok
+}
+
+// parse a decimal like 12.50 or -3, false if s isn't one
+func ParseDecimal(s string) (*Decimal, bool) {
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	whole, frac, point := strings.Cut(s, ".")
+	if whole == "" || (point && frac == "") || !allDigits(whole) || !allDigits(frac) {
+		return nil, false
+	}
+	value, ok := new(big.Int).SetString(sign+whole+frac, 10)
+	if !ok {
+		return nil, false
+	}
+	return &Decimal{Value: value, Scale: len(frac)}, true
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// value of an Integer, BigInt or Decimal as a decimal, false for other
+// objects
+func DecimalValue(obj Object) (*Decimal, bool) {
+	if d, ok := obj.(*Decimal); ok {
+		return d, true
+	}
+	if value, ok := BigValue(obj); ok {
+		return &Decimal{Value: value}, true
+	}
+	return nil, false
+}
+
+// the decimal with scale digits after the point, rounded by mode if it
+// has more than that
+func (d *Decimal) Rescale(scale int, mode RoundingMode) *Decimal {
+	if scale >= d.Scale {
+		value := new(big.Int).Mul(d.Value, pow10(scale-d.Scale))
+		return &Decimal{Value: value, Scale: scale}
+	}
+	return &Decimal{Value: roundQuo(d.Value, pow10(d.Scale-scale), mode), Scale: scale}
+}
+
+func (d *Decimal) Cmp(other *Decimal) int {
+	scale := d.Scale
+	if other.Scale > scale {
+		scale = other.Scale
+	}
+	return d.Rescale(scale, RoundDown).Value.Cmp(other.Rescale(scale, RoundDown).Value)
+}
+
+func (d *Decimal) Neg() *Decimal {
+	return &Decimal{Value: new(big.Int).Neg(d.Value), Scale: d.Scale}
+}
+
+// the sum, exact with the larger scale of the two
+func (d *Decimal) Add(other *Decimal) *Decimal {
+	a, b := aligned(d, other)
+	return &Decimal{Value: new(big.Int).Add(a.Value, b.Value), Scale: a.Scale}
+}
+
+// the difference, exact with the larger scale of the two
+func (d *Decimal) Sub(other *Decimal) *Decimal {
+	a, b := aligned(d, other)
+	return &Decimal{Value: new(big.Int).Sub(a.Value, b.Value), Scale: a.Scale}
+}
+
+// the product, exact with the scales of the two added up
+func (d *Decimal) Mul(other *Decimal) *Decimal {
+	return &Decimal{Value: new(big.Int).Mul(d.Value, other.Value), Scale: d.Scale + other.Scale}
+}
+
+// the quotient with the given scale, or the larger scale of the two if
+// that is larger, rounded by mode. other must not be zero
+func (d *Decimal) Quo(other *Decimal, scale int, mode RoundingMode) *Decimal {
+	if d.Scale > scale {
+		scale = d.Scale
+	}
+	if other.Scale > scale {
+		scale = other.Scale
+	}
+	// d/other * 10^scale, as an integer division
+	num := new(big.Int).Mul(d.Value, pow10(scale+other.Scale-d.Scale))
+	return &Decimal{Value: roundQuo(num, other.Value, mode), Scale: scale}
+}
+
+// the two decimals with the larger scale of them
+func aligned(a, b *Decimal) (*Decimal, *Decimal) {
+	if a.Scale < b.Scale {
+		return a.Rescale(b.Scale, RoundDown), b
+	}
+	return a, b.Rescale(a.Scale, RoundDown)
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// num / den rounded to an integer by mode
+func roundQuo(num, den *big.Int, mode RoundingMode) *big.Int {
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() == 0 {
+		return quo
+	}
+
+	sign := num.Sign() * den.Sign()
+	// how the dropped part compares to a half
+	twice := new(big.Int).Abs(rem)
+	half := twice.Lsh(twice, 1).Cmp(new(big.Int).Abs(den))
+
+	var away bool
+	switch mode {
+	case RoundHalfEven:
+		away = half > 0 || (half == 0 && quo.Bit(0) == 1)
+	case RoundHalfUp:
+		away = half >= 0
+	case RoundHalfDown:
+		away = half > 0
+	case RoundUp:
+		away = true
+	case RoundDown:
+		away = false
+	case RoundCeiling:
+		away = sign > 0
+	case RoundFloor:
+		away = sign < 0
+	}
+	if away {
+		quo.Add(quo, big.NewInt(int64(sign)))
+	}
+	return quo
+}
+
+// order of two numbers, integers or decimals
+func compareNumbers(a, b Object) int {
+	if x, ok := BigValue(a); ok {
+		if y, ok := BigValue(b); ok {
+			return x.Cmp(y)
+		}
+	}
+	x, _ := DecimalValue(a)
+	y, _ := DecimalValue(b)
+	return x.Cmp(y)
+}
@@ -1,8 +1,18 @@
 package object
 
+import "sync"
+
+// bindings of names to values. tasks share the environments of the
+// closures they run, so access is guarded by a lock
 type Environment struct {
+	mu    sync.RWMutex
 	store map[string]Object
 	outer *Environment
+	ctx   *Context // only set on outermost environments
+
+	// only set on the environment of a generator call, passes yielded
+	// values to the generator
+	yield func(Object) bool
 }
 
 func NewEnvironment() *Environment {
@@ -10,8 +20,16 @@ func NewEnvironment() *Environment {
 	return &Environment{store: s, outer: nil}
 }
 
+func NewEnvironmentWithContext(ctx *Context) *Environment {
+	env := NewEnvironment()
+	env.ctx = ctx
+	return env
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
@@ -19,10 +37,56 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
 	e.store[name] = val
+	e.mu.Unlock()
 	return val
 }
 
+// names bound in this environment or the ones enclosing it
+func (e *Environment) Names() []string {
+	var names []string
+	for env := e; env != nil; env = env.outer {
+		env.mu.RLock()
+		for name := range env.store {
+			names = append(names, name)
+		}
+		env.mu.RUnlock()
+	}
+	return names
+}
+
+func (e *Environment) SetYield(yield func(Object) bool) {
+	e.mu.Lock()
+	e.yield = yield
+	e.mu.Unlock()
+}
+
+// yield of the innermost generator call the environment is in, nil
+// outside of generators
+func (e *Environment) Yield() func(Object) bool {
+	for env := e; env != nil; env = env.outer {
+		env.mu.RLock()
+		yield := env.yield
+		env.mu.RUnlock()
+		if yield != nil {
+			return yield
+		}
+	}
+	return nil
+}
+
+// get the context of the outermost environment
+func (e *Environment) Context() *Context {
+	if e.outer != nil {
+		return e.outer.Context()
+	}
+	if e.ctx == nil {
+		return defaultContext
+	}
+	return e.ctx
+}
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
@@ -0,0 +1,177 @@
+package object
+
+import (
+	"sync"
+	"unicode/utf8"
+)
+
+// a sequence of values produced one at a time. Next returns the key and
+// value of the next element, or ok false once the sequence is done. the
+// key is the index for sequences without keys of their own
+type Iterator interface {
+	Object
+	Next() (key, value Object, ok bool)
+}
+
+// objects a loop can go through, each call to Iter starts over
+type Iterable interface {
+	Iter() Iterator
+}
+
+// iterators that hold on to something until they are done, like the
+// goroutine of a generator. a loop left early stops them
+type Stopper interface {
+	Stop()
+}
+
+// iterator over values computed by a function, the built-in collections
+// iterate through one of these
+type funcIterator struct {
+	next func() (key, value Object, ok bool)
+}
+
+func (fi *funcIterator) Type() ObjectType                   { return ITERATOR_OBJ }
+func (fi *funcIterator) Inspect() string                    { return "iterator" }
+func (fi *funcIterator) Next() (key, value Object, ok bool) { return fi.next() }
+func (fi *funcIterator) Iter() Iterator                     { return fi }
+
+// elements with their index. only the elements the array has when the
+// iteration starts are visited
+func (ao *Array) Iter() Iterator {
+	elements, i := ao.Elements, 0
+	return &funcIterator{next: func() (Object, Object, bool) {
+		if i >= len(elements) {
+			return nil, nil, false
+		}
+		i++
+		return &Integer{Value: int64(i - 1)}, elements[i-1], true
+	}}
+}
+
+// pairs in the order of Sorted
+func (h *Hash) Iter() Iterator {
+	pairs, i := h.Sorted(), 0
+	return &funcIterator{next: func() (Object, Object, bool) {
+		if i >= len(pairs) {
+			return nil, nil, false
+		}
+		i++
+		return pairs[i-1].Key, pairs[i-1].Value, true
+	}}
+}
+
+// characters with their byte offset
+func (s *String) Iter() Iterator {
+	str, offset := s.Value, 0
+	return &funcIterator{next: func() (Object, Object, bool) {
+		if offset >= len(str) {
+			return nil, nil, false
+		}
+		r, size := utf8.DecodeRuneInString(str[offset:])
+		offset += size
+		return &Integer{Value: int64(offset - size)}, &String{Value: string(r)}, true
+	}}
+}
+
+// integers of the range with their index
+func (r *Range) Iter() Iterator {
+	n, i := r.Len(), uint64(0)
+	return &funcIterator{next: func() (Object, Object, bool) {
+		if i >= n {
+			return nil, nil, false
+		}
+		i++
+		// wraps around like the difference of Start and End in Len
+		value := r.Start + int64((i-1)*uint64(r.Step))
+		return &Integer{Value: int64(i - 1)}, &Integer{Value: value}, true
+	}}
+}
+
+// values yielded by a call to a generator function. the body runs on a
+// goroutine of its own, taking turns with the code calling Next: it runs
+// until the next yield while Next waits, then waits itself until Next is
+// called again. a generator that is dropped before it's done leaves its
+// goroutine waiting, loops stop the generators they leave early. tasks
+// can share a generator, Next and Stop take turns
+type Generator struct {
+	// evaluate the body, calling yield for each value. yield returns
+	// false once the generator is stopped, the body should then return
+	// as soon as it can
+	Run func(yield func(Object) bool) Object
+
+	mu            sync.Mutex
+	started, done bool
+	count         int64
+	values        chan Object // yielded values, closed when the body returns
+	resume        chan bool   // true to run to the next yield, false to stop
+}
+
+func (g *Generator) Type() ObjectType { return ITERATOR_OBJ }
+func (g *Generator) Inspect() string  { return "generator" }
+
+// a generator can only be iterated once
+func (g *Generator) Iter() Iterator { return g }
+
+// run the body to its next yield. an error raised by the body is the
+// last value of the generator
+func (g *Generator) Next() (key, value Object, ok bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.done {
+		return nil, nil, false
+	}
+
+	if !g.started {
+		g.started = true
+		g.start()
+	} else {
+		g.resume <- true
+	}
+
+	value, ok = <-g.values
+	if !ok || value.Type() == ERROR_OBJ {
+		g.done = true
+	}
+	if !ok {
+		return nil, nil, false
+	}
+	g.count++
+	return &Integer{Value: g.count - 1}, value, true
+}
+
+func (g *Generator) start() {
+	g.values = make(chan Object)
+	g.resume = make(chan bool)
+
+	go func() {
+		stopped := false
+		result := g.Run(func(value Object) bool {
+			if stopped {
+				return false
+			}
+			g.values <- value
+			stopped = !<-g.resume
+			return !stopped
+		})
+		if result != nil && result.Type() == ERROR_OBJ && !stopped {
+			g.values <- result
+		}
+		close(g.values)
+	}()
+}
+
+// make the body return early and wait until it has
+func (g *Generator) Stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.done {
+		return
+	}
+	g.done = true
+	if !g.started {
+		return
+	}
+	g.resume <- false
+	for range g.values {
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"hash/fnv"
 	"intInGo/ast"
+	"intInGo/diag"
+	"sort"
 	"strings"
+	"sync"
 )
 
 type ObjectType string
@@ -17,6 +20,8 @@ type Object interface {
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	BIGINT_OBJ       = "BIGINT"
+	DECIMAL_OBJ      = "DECIMAL"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
@@ -26,6 +31,15 @@ const (
 	STRING_OBJ       = "STRING"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	STRUCT_TYPE_OBJ  = "STRUCT_TYPE"
+	STRUCT_OBJ       = "STRUCT"
+	RANGE_OBJ        = "RANGE"
+	ITERATOR_OBJ     = "ITERATOR"
+	TASK_OBJ         = "TASK"
+	CHANNEL_OBJ      = "CHANNEL"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	TAIL_CALL_OBJ    = "TAIL_CALL"
 )
 
 type Integer struct {
@@ -79,10 +93,26 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// a call in tail position that is yet to be made. the function it is in
+// returns it instead of making the call, so recursion through tail calls
+// doesn't grow the stack
+type TailCall struct {
+	Fn   Object
+	Args []Object
+	Span diag.Span // of the call, for errors raised by making it
+}
+
+func (tc *TailCall) Type() ObjectType { return TAIL_CALL_OBJ }
+func (tc *TailCall) Inspect() string  { return "tail call" }
+
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   map[*ast.Identifier]ast.Expression // evaluated on each call that omits them
+	Rest       *ast.Identifier                    // bound to an array of the extra arguments
+	Patterns   map[*ast.Identifier]ast.Pattern    // destructure their argument instead of binding it
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Generator  bool // calls return a generator running the body
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -91,7 +121,14 @@ func (f *Function) Inspect() string {
 
 	params := []string{}
 	for _, p := range f.Parameters {
-		params = append(params, p.String())
+		if def, ok := f.Defaults[p]; ok {
+			params = append(params, p.String()+" = "+def.String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
 	}
 
 	out.WriteString("fn")
@@ -104,7 +141,7 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
-type BuiltInFunction func(args ...Object) Object
+type BuiltInFunction func(ctx *Context, args ...Object) Object
 type BuiltIn struct {
 	Fn BuiltInFunction
 }
@@ -155,7 +192,7 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.Sorted() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 
@@ -166,9 +203,156 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+// pairs of the hash ordered by key, numbers before booleans before strings
+func (h *Hash) Sorted() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		a, b := pairs[i].Key, pairs[j].Key
+		if keyRank(a) != keyRank(b) {
+			return keyRank(a) < keyRank(b)
+		}
+		switch a := a.(type) {
+		case *Integer, *BigInt, *Decimal:
+			return compareNumbers(a, b) < 0
+		case *Boolean:
+			return !a.Value && b.(*Boolean).Value
+		case *String:
+			return a.Value < b.(*String).Value
+		}
+		return false
+	})
+	return pairs
+}
+
+func keyRank(key Object) int {
+	switch key.Type() {
+	case INTEGER_OBJ, BIGINT_OBJ, DECIMAL_OBJ:
+		return 0
+	case BOOLEAN_OBJ:
+		return 1
+	}
+	return 2
+}
+
+// integers from Start up to End, exclusive, Step apart. nothing is
+// allocated for the elements, they are computed as the range is iterated
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+
+// number of integers in the range. counted in uint64 since a range can
+// span more than the largest int64
+func (r *Range) Len() uint64 {
+	switch {
+	case r.Step > 0 && r.End > r.Start:
+		return (uint64(r.End)-uint64(r.Start)-1)/uint64(r.Step) + 1
+	case r.Step < 0 && r.End < r.Start:
+		return (uint64(r.Start)-uint64(r.End)-1)/uint64(-r.Step) + 1
+	}
+	return 0
+}
+
+// signals a loop body ended with break or continue, passed up through the
+// enclosing blocks like a return value
+type LoopControl struct {
+	Kind ObjectType // BREAK_OBJ or CONTINUE_OBJ
+}
+
+func (lc *LoopControl) Type() ObjectType { return lc.Kind }
+func (lc *LoopControl) Inspect() string  { return strings.ToLower(string(lc.Kind)) }
+
 type Error struct {
 	Message string
+	Code    string    // kind of error, one of the diag codes
+	Span    diag.Span // source the error was raised at, if known
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Diagnostic() diag.Diagnostic {
+	return diag.Diagnostic{
+		Severity: diag.Error,
+		Code:     e.Code,
+		Span:     e.Span,
+		Message:  e.Message,
+	}
+}
+
+// type declared by a struct statement, calling it constructs an instance
+// from the values of the fields in order
+type StructType struct {
+	Name   string
+	Fields []string
+}
+
+func (st *StructType) Type() ObjectType { return STRUCT_TYPE_OBJ }
+func (st *StructType) Inspect() string {
+	return "struct " + st.Name + " { " + strings.Join(st.Fields, ", ") + " }"
+}
+
+func (st *StructType) HasField(name string) bool {
+	for _, f := range st.Fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// instance of a struct type. tasks can share a struct, so its fields are
+// guarded by a lock
+type Struct struct {
+	Def *StructType
+
+	mu     sync.RWMutex
+	fields map[string]Object
+}
+
+// instance of def with the given values of its fields in order
+func NewStruct(def *StructType, values []Object) *Struct {
+	fields := make(map[string]Object, len(values))
+	for i, name := range def.Fields {
+		fields[name] = values[i]
+	}
+	return &Struct{Def: def, fields: fields}
+}
+
+func (s *Struct) Get(name string) Object {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.fields[name]
+}
+
+func (s *Struct) Set(name string, val Object) Object {
+	s.mu.Lock()
+	s.fields[name] = val
+	s.mu.Unlock()
+	return val
+}
+
+func (s *Struct) Type() ObjectType { return STRUCT_OBJ }
+func (s *Struct) Inspect() string {
+	var out bytes.Buffer
+
+	fields := []string{}
+	for _, name := range s.Def.Fields {
+		fields = append(fields, name+": "+s.Get(name).Inspect())
+	}
+
+	out.WriteString(s.Def.Name)
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
@@ -1,6 +1,11 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -20,3 +25,229 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestBigIntHashKey(t *testing.T) {
+	parse := func(s string) *BigInt {
+		n, _ := new(big.Int).SetString(s, 10)
+		return &BigInt{Value: n}
+	}
+	big1 := parse("123456789012345678901234567890")
+	big2 := parse("123456789012345678901234567890")
+	negative := parse("-123456789012345678901234567890")
+
+	if big1.HashKey() != big2.HashKey() {
+		t.Errorf("big integers with same value have different hash keys")
+	}
+	if big1.HashKey() == negative.HashKey() {
+		t.Errorf("big integers with different signs have same hash keys")
+	}
+
+	// values that fit in an int64 are always Integers
+	if _, ok := NewBigInt(big.NewInt(42)).(*Integer); !ok {
+		t.Errorf("small value not turned into an Integer")
+	}
+	if _, ok := NewBigInt(big1.Value).(*BigInt); !ok {
+		t.Errorf("large value not kept as a BigInt")
+	}
+
+	hash := &Hash{Pairs: map[HashKey]HashPair{
+		big1.HashKey():                  {Key: big1, Value: &Integer{Value: 1}},
+		(&Integer{Value: 5}).HashKey():  {Key: &Integer{Value: 5}, Value: &Integer{Value: 2}},
+		negative.HashKey():              {Key: negative, Value: &Integer{Value: 3}},
+		(&String{Value: "a"}).HashKey(): {Key: &String{Value: "a"}, Value: &Integer{Value: 4}},
+	}}
+	expected := "{-123456789012345678901234567890: 3, 5: 2, 123456789012345678901234567890: 1, a: 4}"
+	if hash.Inspect() != expected {
+		t.Errorf("wrong inspect. want=%q, got=%q", expected, hash.Inspect())
+	}
+}
+
+func TestDecimals(t *testing.T) {
+	dec := func(s string) *Decimal {
+		d, ok := ParseDecimal(s)
+		if !ok {
+			t.Fatalf("could not parse %q", s)
+		}
+		return d
+	}
+
+	inspect := []struct{ input, expected string }{
+		{"12.50", "12.50"},
+		{"-0.05", "-0.05"},
+		{"0.5", "0.5"},
+		{"+7", "7"},
+		{"007.10", "7.10"},
+	}
+	for _, tt := range inspect {
+		if got := dec(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong inspect for %q. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+	for _, invalid := range []string{"", ".5", "1.", "1.2.3", "1e3", "-", "abc"} {
+		if _, ok := ParseDecimal(invalid); ok {
+			t.Errorf("parsed invalid decimal %q", invalid)
+		}
+	}
+
+	rounding := []struct {
+		input    string
+		mode     RoundingMode
+		expected string
+	}{
+		{"2.5", RoundHalfEven, "2"},
+		{"3.5", RoundHalfEven, "4"},
+		{"-2.5", RoundHalfEven, "-2"},
+		{"2.51", RoundHalfEven, "3"},
+		{"2.5", RoundHalfUp, "3"},
+		{"-2.5", RoundHalfUp, "-3"},
+		{"2.5", RoundHalfDown, "2"},
+		{"2.6", RoundHalfDown, "3"},
+		{"2.1", RoundUp, "3"},
+		{"-2.1", RoundUp, "-3"},
+		{"2.9", RoundDown, "2"},
+		{"-2.9", RoundDown, "-2"},
+		{"-2.1", RoundCeiling, "-2"},
+		{"2.1", RoundCeiling, "3"},
+		{"2.9", RoundFloor, "2"},
+		{"-2.1", RoundFloor, "-3"},
+		{"2", RoundHalfEven, "2"},
+	}
+	for _, tt := range rounding {
+		if got := dec(tt.input).Rescale(0, tt.mode).Inspect(); got != tt.expected {
+			t.Errorf("wrong rounding of %s by mode %d. want=%s, got=%s", tt.input, tt.mode, tt.expected, got)
+		}
+	}
+
+	if got := dec("10.00").Quo(dec("3"), 0, RoundHalfEven).Inspect(); got != "3.33" {
+		t.Errorf("wrong quotient. want=3.33, got=%s", got)
+	}
+	if got := dec("1").Quo(dec("8"), 4, RoundHalfEven).Inspect(); got != "0.1250" {
+		t.Errorf("wrong quotient. want=0.1250, got=%s", got)
+	}
+	if got := dec("1.10").Add(dec("2.2")).Inspect(); got != "3.30" {
+		t.Errorf("wrong sum. want=3.30, got=%s", got)
+	}
+	if got := dec("1.5").Mul(dec("-1.5")).Inspect(); got != "-2.25" {
+		t.Errorf("wrong product. want=-2.25, got=%s", got)
+	}
+	if dec("1.5").Cmp(dec("1.50")) != 0 || dec("1.5").Cmp(dec("1.49")) != 1 {
+		t.Errorf("wrong comparison")
+	}
+
+	if dec("1.5").HashKey() != dec("1.500").HashKey() {
+		t.Errorf("equal decimals with different scales have different hash keys")
+	}
+	if dec("1.5").HashKey() == dec("15").HashKey() || dec("10").HashKey() == dec("1").HashKey() {
+		t.Errorf("different decimals have same hash keys")
+	}
+}
+
+func TestIterators(t *testing.T) {
+	str := func(s string) *String { return &String{Value: s} }
+	tests := []struct {
+		iterable Iterable
+		expected string
+	}{
+		{&Array{Elements: []Object{str("a"), str("b")}}, "0:a 1:b "},
+		{&Hash{Pairs: map[HashKey]HashPair{
+			str("y").HashKey(): {Key: str("y"), Value: str("2")},
+			str("x").HashKey(): {Key: str("x"), Value: str("1")},
+		}}, "x:1 y:2 "},
+		{str("hé!"), "0:h 1:é 3:! "},
+		{&Range{Start: 5, End: 0, Step: -2}, "0:5 1:3 2:1 "},
+		{&Range{Start: 0, End: 5, Step: -1}, ""},
+		{&Range{Start: math.MaxInt64 - 1, End: math.MaxInt64, Step: 1}, "0:9223372036854775806 "},
+		{&Range{Start: math.MinInt64, End: math.MaxInt64, Step: math.MaxInt64}, "0:-9223372036854775808 1:-1 2:9223372036854775806 "},
+		{&Range{Start: math.MaxInt64, End: math.MinInt64, Step: math.MinInt64}, "0:9223372036854775807 1:-1 "},
+		{&Generator{Run: func(yield func(Object) bool) Object {
+			_ = yield(str("a")) && yield(str("b"))
+			return nil
+		}}, "0:a 1:b "},
+	}
+
+	for _, tt := range tests {
+		got := ""
+		iter := tt.iterable.Iter()
+		for {
+			key, value, ok := iter.Next()
+			if !ok {
+				break
+			}
+			got += key.Inspect() + ":" + value.Inspect() + " "
+		}
+		if got != tt.expected {
+			t.Errorf("wrong elements for %s. got=%q, want=%q", tt.iterable.(Object).Inspect(), got, tt.expected)
+		}
+	}
+}
+
+func TestRangeLen(t *testing.T) {
+	tests := []struct {
+		r        *Range
+		expected uint64
+	}{
+		{&Range{Start: 0, End: 10, Step: 3}, 4},
+		{&Range{Start: 10, End: 0, Step: -4}, 3},
+		{&Range{Start: 0, End: 0, Step: 1}, 0},
+		{&Range{Start: 5, End: 0, Step: 1}, 0},
+		{&Range{Start: -math.MaxInt64, End: math.MaxInt64, Step: 1}, math.MaxUint64 - 1},
+		{&Range{Start: math.MinInt64, End: math.MaxInt64, Step: 1}, math.MaxUint64},
+		{&Range{Start: math.MaxInt64, End: math.MinInt64, Step: -1}, math.MaxUint64},
+		{&Range{Start: math.MinInt64, End: math.MaxInt64, Step: math.MaxInt64}, 3},
+		{&Range{Start: math.MaxInt64, End: math.MinInt64, Step: math.MinInt64}, 2},
+	}
+
+	for _, tt := range tests {
+		if got := tt.r.Len(); got != tt.expected {
+			t.Errorf("wrong length of %s. want=%d, got=%d", tt.r.Inspect(), tt.expected, got)
+		}
+	}
+}
+
+func TestGeneratorStop(t *testing.T) {
+	returned := false
+	g := &Generator{Run: func(yield func(Object) bool) Object {
+		for i := int64(0); yield(&Integer{Value: i}); i++ {
+		}
+		returned = true
+		return nil
+	}}
+
+	for i := 0; i < 3; i++ {
+		g.Next()
+	}
+	g.Stop()
+
+	if !returned {
+		t.Errorf("body still running after Stop")
+	}
+	if _, _, ok := g.Next(); ok {
+		t.Errorf("stopped generator produced a value")
+	}
+}
+
+func TestChannelCloseWakesSenders(t *testing.T) {
+	c := NewChannel(0)
+	sent := make(chan bool)
+	go func() { sent <- c.Send(&Integer{Value: 1}) }()
+	// give the sender time to block on the unbuffered channel
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan bool)
+	go func() { closed <- c.Close() }()
+
+	select {
+	case ok := <-closed:
+		if !ok {
+			t.Errorf("Close of an open channel returned false")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Close blocked by a pending send")
+	}
+	if <-sent {
+		t.Errorf("send on a channel closed while waiting succeeded")
+	}
+	if c.Close() {
+		t.Errorf("second Close returned true")
+	}
+}
@@ -3,16 +3,46 @@
 package parser
 
 import (
-	"fmt"
+	"errors"
 	"intInGo/ast"
+	"intInGo/diag"
 	"intInGo/lexer"
 	"intInGo/token"
+	"io"
+	"math/big"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 type Parser struct {
 	l      *lexer.Lexer // ptr to instance of lexer
-	errors []string
+	errors []diag.Diagnostic
+
+	// set after an error until the parser has synchronized on the next
+	// statement boundary, errors reported meanwhile are cascades and dropped
+	panicking bool
+	tokenIdx  int               // number of tokens consumed so far
+	reported  map[errorKey]bool // errors already reported, by token
+
+	// set when recovery stopped on a closing brace the broken statement
+	// didn't consume, so the enclosing block still gets to see it
+	unconsumedBrace bool
+
+	// braces opened and not yet closed up to and including curToken
+	braceDepth int
+
+	// number of loops around the current statement, break and continue
+	// need one within the same function
+	loops int
+
+	// function whose body is being parsed, a yield makes it a generator
+	function *ast.FunctionLiteral
+
+	// when set, the parse functions write where they begin and end to it,
+	// indented by how deeply they are nested
+	Trace      io.Writer
+	traceLevel int
 
 	curToken  token.Token // point to current token
 	peekToken token.Token // point to next token
@@ -24,24 +54,30 @@ type Parser struct {
 
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
-		l:      l,
-		errors: []string{},
+		l:        l,
+		errors:   []diag.Diagnostic{},
+		reported: make(map[errorKey]bool),
 	}
 
 	// initialize prefix parsing functions map
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.DECIMAL, p.parseDecimalLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNullLiteral)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
+	p.registerPrefix(token.YIELD, p.parseYieldExpression)
+	p.registerPrefix(token.SELECT, p.parseSelectExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -52,8 +88,13 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.NULLISH, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.OPTIONAL_LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.OPTIONAL_LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
 
 	// read two tokens
 	p.nextToken()
@@ -62,6 +103,15 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// precedence an infix operator of the given token type binds with, LOWEST
+// for tokens that aren't infix operators
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
+}
+
 // get precedence associated with type of peek token
 func (p *Parser) peekPrecedence() int {
 	if p, ok := precedences[p.peekToken.Type]; ok {
@@ -78,21 +128,28 @@ func (p *Parser) curPrecedence() int {
 
 // operator precedences (increasing order)
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:            ASSIGN,
+	token.NULLISH:           COALESCE,
+	token.EQ:                EQUALS,
+	token.NOT_EQ:            EQUALS,
+	token.LT:                LESSGREATER,
+	token.GT:                LESSGREATER,
+	token.PLUS:              SUM,
+	token.MINUS:             SUM,
+	token.SLASH:             PRODUCT,
+	token.ASTERISK:          PRODUCT,
+	token.LPAREN:            CALL,
+	token.OPTIONAL_LPAREN:   CALL,
+	token.LBRACKET:          INDEX,
+	token.OPTIONAL_LBRACKET: INDEX,
+	token.DOT:               INDEX,
 }
 
 const (
 	_ int = iota // iota gives these constants incrementing numbers as values
 	LOWEST
+	ASSIGN      // p.x = 5
+	COALESCE    // a ?? b
 	EQUALS      // ==
 	LESSGREATER // >, <
 	SUM         // +
@@ -106,6 +163,14 @@ const (
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken() // request new token from lexer
+	p.tokenIdx++
+
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.braceDepth++
+	case token.RBRACE:
+		p.braceDepth--
+	}
 }
 
 // entry point to recursive descent parser
@@ -122,6 +187,8 @@ func (p *Parser) ParseProgram() *ast.Program {
 			// add parsed statement to Statements slice of root
 			program.Statements = append(program.Statements, stmt)
 		}
+		// a stray closing brace at the top level is simply skipped
+		p.unconsumedBrace = false
 		// advance current and next tokens
 		p.nextToken()
 	}
@@ -131,27 +198,117 @@ func (p *Parser) ParseProgram() *ast.Program {
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	start := p.curToken
+	// brace depth outside of the statement, a brace starting it is its own
+	depth := p.braceDepth
+	if start.Type == token.LBRACE {
+		depth--
+	}
+
+	// only assign non-nil results, so a failed parse leaves stmt nil instead
+	// of holding a typed nil pointer
+	var stmt ast.Statement
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if s := p.parseLetStatement(); s != nil {
+			stmt = s
+		}
 	case token.RETURN:
-		return p.parseReturnStatement()
+		if s := p.parseReturnStatement(); s != nil {
+			stmt = s
+		}
+	case token.STRUCT:
+		if s := p.parseStructStatement(); s != nil {
+			stmt = s
+		}
+	case token.FOR:
+		if s := p.parseForStatement(); s != nil {
+			stmt = s
+		}
+	case token.BREAK, token.CONTINUE:
+		if s := p.parseLoopControl(); s != nil {
+			stmt = s
+		}
 	default:
-		return p.parseExpressionStatement()
+		if s := p.parseExpressionStatement(); s != nil {
+			stmt = s
+		}
+	}
+
+	// recover from errors in this statement and keep a placeholder for it
+	if p.panicking {
+		p.synchronize(depth)
+		if stmt == nil {
+			stmt = &ast.BadStatement{Token: start}
+		}
+	}
+
+	return stmt
+}
+
+// skip tokens until the end of the broken statement: a semicolon, or just
+// before a closing brace or the keyword starting the next statement. depth
+// is the brace depth outside of the statement, so braces the statement
+// opened, before or after the error, are skipped as a whole
+func (p *Parser) synchronize(depth int) {
+	defer func() { p.panicking = false }()
+
+	for !p.curTokenIs(token.EOF) {
+		// at the closing brace of an enclosing block
+		if p.braceDepth < depth {
+			p.unconsumedBrace = true
+			return
+		}
+
+		if p.braceDepth == depth {
+			if p.curTokenIs(token.SEMICOLON) {
+				break
+			}
+			if p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) ||
+				isStatementKeyword(p.peekToken.Type) {
+				break
+			}
+		}
+		p.nextToken()
 	}
 }
 
+func isStatementKeyword(t token.TokenType) bool {
+	switch t {
+	case token.LET, token.RETURN, token.STRUCT, token.FOR, token.BREAK, token.CONTINUE:
+		return true
+	}
+	return false
+}
+
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	// construct a let statement node with the current token
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	// expect an identifier
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		// destructuring an array or hash
+		p.nextToken()
+		if stmt.Pattern = p.parsePattern(map[string]bool{}); stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		// expect an identifier
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		// construct an identifier node
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	}
 
-	// construct an identifier node
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	// optional type annotation
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		if stmt.Type = p.parseType(); stmt.Type == nil {
+			return nil
+		}
+	}
 
 	// expect an equal sign
 	if !p.expectPeek(token.ASSIGN) {
@@ -162,14 +319,118 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
-	// advance until end of statement
-	if p.peekTokenIs(token.SEMICOLON) {
+	// advance until end of statement (left to synchronize() after an error)
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
 	return stmt
 }
 
+// parse struct Point { x, y }
+func (p *Parser) parseStructStatement() *ast.StructStatement {
+	stmt := &ast.StructStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seen[field.Value] {
+			p.addError(diag.Errorf(
+				diag.DuplicateField,
+				diag.TokenSpan(field.Token),
+				"duplicate field %s in struct %s", field.Value, stmt.Name.Value,
+			))
+			return nil
+		}
+		seen[field.Value] = true
+		stmt.Fields = append(stmt.Fields, field)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parse for (x in xs) { ... } or for (k, v in xs) { ... }
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) || !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Key = stmt.Value
+		stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.loops++
+	stmt.Body = p.parseBlockStatement()
+	p.loops--
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parse break or continue, which only make sense inside a loop
+func (p *Parser) parseLoopControl() ast.Statement {
+	tok := p.curToken
+	if p.loops == 0 {
+		p.addError(diag.Errorf(
+			diag.MisplacedKeyword,
+			diag.TokenSpan(tok),
+			"%s outside of a loop", tok.Literal,
+		))
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok}
+	}
+	return &ast.ContinueStatement{Token: tok}
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	// construct a return statement node with the current token
 	stmt := &ast.ReturnStatement{Token: p.curToken}
@@ -178,7 +439,7 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	if p.peekTokenIs(token.SEMICOLON) {
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -186,13 +447,13 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	//defer untrace(trace("parseExpressionStatement"))
+	defer p.untrace(p.trace("parseExpressionStatement"))
 
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
 
 	// expressions have optional semicolons
-	if p.peekTokenIs(token.SEMICOLON) {
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -210,6 +471,11 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
+		if p.unconsumedBrace {
+			// curToken is this block's closing brace
+			p.unconsumedBrace = false
+			continue
+		}
 		p.nextToken()
 	}
 
@@ -218,16 +484,21 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
 // heart of Pratt parser
 func (p *Parser) parseExpression(precedence int) ast.Expression {
-	//defer untrace(trace("parseExpression"))
+	defer p.untrace(p.trace("parseExpression"))
+
+	start := p.curToken
 
 	// check if parsing fn associated with current token type in prefix position
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
-		return nil
+		return &ast.BadExpression{Token: start}
 	}
 	// if so, call it
 	leftExp := prefix()
+	if leftExp == nil {
+		leftExp = &ast.BadExpression{Token: start}
+	}
 
 	// until encountering a token with lower precedence...
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
@@ -241,6 +512,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		// if found, call it
 		p.nextToken()
 		leftExp = infix(leftExp)
+		if leftExp == nil {
+			leftExp = &ast.BadExpression{Token: start}
+		}
 	}
 
 	return leftExp
@@ -287,8 +561,214 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm := &ast.MatchArm{Pattern: p.parsePattern(map[string]bool{})}
+		if arm.Pattern == nil {
+			return nil
+		}
+
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			p.nextToken()
+			arm.Guard = p.parseExpression(LOWEST)
+		}
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+		arm.Token = p.curToken
+
+		p.nextToken()
+		arm.Body = p.parseExpression(LOWEST)
+		expression.Arms = append(expression.Arms, arm)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	return expression
+}
+
+// parse the pattern starting at the current token. bound holds the names
+// bound so far, a pattern can bind each name only once
+func (p *Parser) parsePattern(bound map[string]bool) ast.Pattern {
+	switch p.curToken.Type {
+	case token.INT, token.DECIMAL, token.STRING, token.TRUE, token.FALSE, token.NULL:
+		value := p.prefixParseFns[p.curToken.Type]()
+		if value == nil {
+			return nil
+		}
+		return &ast.LiteralPattern{Value: value}
+
+	case token.MINUS:
+		minus := p.curToken
+		var value ast.Expression
+		switch {
+		case p.peekTokenIs(token.DECIMAL):
+			p.nextToken()
+			value = p.parseDecimalLiteral()
+		case p.expectPeek(token.INT):
+			value = p.parseIntegerLiteral()
+		default:
+			return nil
+		}
+		if value == nil {
+			return nil
+		}
+		return &ast.LiteralPattern{Value: &ast.PrefixExpression{Token: minus, Operator: "-", Right: value}}
+
+	case token.IDENT:
+		if p.curToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: p.curToken}
+		}
+		name := p.patternName(bound)
+		if name == nil {
+			return nil
+		}
+		return &ast.BindingPattern{Name: name}
+
+	case token.LBRACKET:
+		return p.parseArrayPattern(bound)
+
+	case token.LBRACE:
+		return p.parseHashPattern(bound)
+	}
+
+	p.addError(diag.Errorf(
+		diag.InvalidPattern,
+		diag.TokenSpan(p.curToken),
+		"expected a pattern, got %s", p.curToken.Type,
+	))
+	return nil
+}
+
+// the identifier at the current token as a name bound by a pattern
+func (p *Parser) patternName(bound map[string]bool) *ast.Identifier {
+	name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if name.Value == "_" {
+		return name
+	}
+	if bound[name.Value] {
+		p.addError(diag.Errorf(
+			diag.DuplicateBinding,
+			diag.TokenSpan(name.Token),
+			"%s bound more than once in pattern", name.Value,
+		))
+		return nil
+	}
+	bound[name.Value] = true
+	return name
+}
+
+func (p *Parser) parseArrayPattern(bound map[string]bool) ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+
+		// the rest comes last and takes the remaining elements
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			if pattern.Rest = p.patternName(bound); pattern.Rest == nil {
+				return nil
+			}
+			break
+		}
+
+		el := p.parsePattern(bound)
+		if el == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, el)
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return pattern
+}
+
+func (p *Parser) parseHashPattern(bound map[string]bool) ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		// a name alone binds the value of the key of the same name
+		if p.curTokenIs(token.IDENT) && !p.peekTokenIs(token.COLON) {
+			name := p.patternName(bound)
+			if name == nil {
+				return nil
+			}
+			pattern.Keys = append(pattern.Keys, &ast.StringLiteral{Token: name.Token, Value: name.Value})
+			pattern.Values = append(pattern.Values, &ast.BindingPattern{Name: name})
+
+			if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+			continue
+		}
+
+		var key ast.Expression
+		switch p.curToken.Type {
+		case token.INT, token.STRING, token.TRUE, token.FALSE:
+			key = p.prefixParseFns[p.curToken.Type]()
+		default:
+			p.addError(diag.Errorf(
+				diag.InvalidPattern,
+				diag.TokenSpan(p.curToken),
+				"expected a literal hash key, got %s", p.curToken.Type,
+			))
+		}
+		if key == nil || !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parsePattern(bound)
+		if value == nil {
+			return nil
+		}
+		pattern.Keys = append(pattern.Keys, key)
+		pattern.Values = append(pattern.Values, value)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	return pattern
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+	exp.Optional = p.curTokenIs(token.OPTIONAL_LPAREN)
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
 	return exp
 }
@@ -317,12 +797,21 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	// the lexer already reported the illegal character, only recover from it
+	if t == token.ILLEGAL {
+		p.panicking = true
+		return
+	}
+
+	p.addError(diag.Errorf(
+		diag.MissingExpression,
+		diag.TokenSpan(p.curToken),
+		"no prefix parse function for %s found", t,
+	))
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
-	//defer untrace(trace("parsePrefixExpression"))
+	defer p.untrace(p.trace("parsePrefixExpression"))
 
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -337,7 +826,7 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
-	//defer untrace(trace("parseInfixExpression"))
+	defer p.untrace(p.trace("parseInfixExpression"))
 
 	expression := &ast.InfixExpression{
 		Token:    p.curToken,
@@ -351,16 +840,66 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: left}
+
+	// 1.5 is a member access on 1, decimals are written 1.5d
+	if lit, ok := left.(*ast.IntegerLiteral); ok && p.peekTokenIs(token.INT) {
+		p.addError(diag.Errorf(
+			diag.InvalidInteger,
+			diag.Span{Start: lit.Token.Pos, End: p.peekToken.End},
+			"decimal literals need a d suffix, like %s.%sd", lit.Token.Literal, p.peekToken.Literal,
+		))
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+// only fields can be assigned to, the value may be another assignment
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	exp := &ast.AssignExpression{Token: p.curToken, Target: left}
+
+	if _, ok := left.(*ast.MemberExpression); !ok {
+		p.addError(diag.Errorf(
+			diag.InvalidAssignment,
+			diag.TokenSpan(p.curToken),
+			"cannot assign to %s", left.String(),
+		))
+		return nil
+	}
+
+	p.nextToken()
+	exp.Value = p.parseExpression(LOWEST)
+
+	return exp
+}
+
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	//defer untrace(trace("parseIntegerLiteral"))
+	defer p.untrace(p.trace("parseIntegerLiteral"))
 
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
-	// convert string in current token literal to an int64
+	// convert string in current token literal to an int64, or a big.Int
+	// if it is too large for one
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if n, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			lit.Big = n
+			return lit
+		}
+	}
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(diag.Errorf(
+			diag.InvalidInteger,
+			diag.TokenSpan(p.curToken),
+			"could not parse %q as integer", p.curToken.Literal,
+		))
 		return nil
 	}
 
@@ -369,6 +908,26 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseDecimalLiteral() ast.Expression {
+	lit := &ast.DecimalLiteral{Token: p.curToken}
+
+	// digits of 12.50d without the point and suffix, and the number of
+	// them after the point
+	whole, frac, _ := strings.Cut(strings.TrimSuffix(p.curToken.Literal, "d"), ".")
+	value, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok {
+		p.addError(diag.Errorf(
+			diag.InvalidInteger,
+			diag.TokenSpan(p.curToken),
+			"could not parse %q as decimal", p.curToken.Literal,
+		))
+		return nil
+	}
+
+	lit.Value, lit.Scale = value, len(frac)
+	return lit
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
@@ -380,43 +939,299 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		if lit.ReturnType = p.parseType(); lit.ReturnType == nil {
+			return nil
+		}
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+	// loops around the function don't reach into its body
+	loops, function := p.loops, p.function
+	p.loops, p.function = 0, lit
 	lit.Body = p.parseBlockStatement()
+	p.loops, p.function = loops, function
 
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	// construct slice of params by repeatedly building identifiers from comma separated list
-	identifiers := []*ast.Identifier{}
+func (p *Parser) parseSelectExpression() ast.Expression {
+	expression := &ast.SelectExpression{Token: p.curToken}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
 
-	if p.peekTokenIs(token.RPAREN) {
+	hasDefault := false
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		c := p.parseSelectCase()
+		if c == nil {
+			return nil
+		}
+		if c.Channel == nil {
+			if hasDefault {
+				p.addError(diag.Errorf(
+					diag.InvalidSelectCase,
+					diag.TokenSpan(c.Op),
+					"select has more than one default case",
+				))
+				return nil
+			}
+			hasDefault = true
+		}
+
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+		c.Token = p.curToken
+
+		p.nextToken()
+		c.Body = p.parseExpression(LOWEST)
+		expression.Cases = append(expression.Cases, c)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	return expression
+}
+
+// parse the operation of a select case: _, recv(ch), name = recv(ch) or
+// send(ch, value). the method forms ch.recv() and ch.send(value) work too
+func (p *Parser) parseSelectCase() *ast.SelectCase {
+	c := &ast.SelectCase{Op: p.curToken}
+	if p.curTokenIs(token.IDENT) && p.curToken.Literal == "_" {
+		return c
+	}
+	if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.ASSIGN) {
+		c.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.nextToken()
 		p.nextToken()
-		return identifiers
 	}
 
+	start := p.curToken
+	op := p.parseExpression(LOWEST)
+	var args []ast.Expression
+	if call, ok := op.(*ast.CallExpression); ok {
+		switch fn := call.Function.(type) {
+		case *ast.Identifier:
+			c.Op, args = fn.Token, call.Arguments
+		case *ast.MemberExpression:
+			c.Op, args = fn.Property.Token, append([]ast.Expression{fn.Object}, call.Arguments...)
+		}
+	}
+
+	switch {
+	case c.Op.Literal == "recv" && len(args) == 1:
+		c.Channel = args[0]
+	case c.Op.Literal == "send" && len(args) == 2 && c.Name == nil:
+		c.Channel, c.Value = args[0], args[1]
+	default:
+		if op != nil {
+			p.addError(diag.Errorf(
+				diag.InvalidSelectCase,
+				diag.TokenSpan(start),
+				"expected recv(channel) or send(channel, value) in select, got %s", op.String(),
+			))
+		}
+		return nil
+	}
+	return c
+}
+
+// parse yield value, which turns the enclosing function into a generator
+func (p *Parser) parseYieldExpression() ast.Expression {
+	exp := &ast.YieldExpression{Token: p.curToken}
+	if p.function == nil {
+		p.addError(diag.Errorf(
+			diag.MisplacedKeyword,
+			diag.TokenSpan(exp.Token),
+			"yield outside of a function",
+		))
+		return nil
+	}
+	p.function.Generator = true
+
 	p.nextToken()
+	exp.Value = p.parseExpression(LOWEST)
+	if exp.Value == nil {
+		return nil
+	}
+	return exp
+}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+// parse a parameter list like (a, b = 10, ...rest) into the literal.
+// parameters with defaults can't be followed by ones without, and the
+// rest parameter comes last
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
 
-	for p.peekTokenIs(token.COMMA) {
+	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
+		return true
+	}
+
+	for {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.parseParameterType(lit, lit.Rest) {
+				return false
+			}
+			break
+		}
+
+		var ident *ast.Identifier
+		if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+			p.nextToken()
+			start := p.curToken
+			pattern := p.parsePattern(map[string]bool{})
+			if pattern == nil {
+				return false
+			}
+			// the pattern destructures an argument bound to no name
+			ident = &ast.Identifier{Token: start, Value: pattern.String()}
+			if lit.Patterns == nil {
+				lit.Patterns = map[*ast.Identifier]ast.Pattern{}
+			}
+			lit.Patterns[ident] = pattern
+		} else {
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			ident = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		}
+		lit.Parameters = append(lit.Parameters, ident)
+		if !p.parseParameterType(lit, ident) {
+			return false
+		}
+
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			if lit.Defaults == nil {
+				lit.Defaults = map[*ast.Identifier]ast.Expression{}
+			}
+			lit.Defaults[ident] = p.parseExpression(LOWEST)
+		} else if len(lit.Defaults) > 0 {
+			p.addError(diag.Errorf(
+				diag.UnexpectedToken,
+				diag.TokenSpan(ident.Token),
+				"parameter %s without default follows parameter with default", ident.Value,
+			))
+			return false
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	return p.expectPeek(token.RPAREN)
+}
+
+// parse the optional annotation after a parameter (x: int)
+func (p *Parser) parseParameterType(lit *ast.FunctionLiteral, param *ast.Identifier) bool {
+	if !p.peekTokenIs(token.COLON) {
+		return true
+	}
+	p.nextToken()
+	p.nextToken()
+
+	typ := p.parseType()
+	if typ == nil {
+		return false
+	}
+	if lit.Types == nil {
+		lit.Types = map[*ast.Identifier]*ast.TypeAnnotation{}
+	}
+	lit.Types[param] = typ
+	return true
+}
+
+// parse a type annotation starting at the current token, like int,
+// array<int> or fn(int, string): bool
+func (p *Parser) parseType() *ast.TypeAnnotation {
+	typ := &ast.TypeAnnotation{Token: p.curToken, Name: p.curToken.Literal}
+
+	switch p.curToken.Type {
+	case token.FUNCTION:
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		params, ok := p.parseTypeList(token.RPAREN)
+		if !ok {
+			return nil
+		}
+		typ.Params = params
+
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			if typ.Result = p.parseType(); typ.Result == nil {
+				return nil
+			}
+		}
+	case token.IDENT, token.NULL:
+		if p.peekTokenIs(token.LT) {
+			p.nextToken()
+			params, ok := p.parseTypeList(token.GT)
+			if !ok {
+				return nil
+			}
+			typ.Params = params
+		}
+	default:
+		p.addError(diag.Errorf(
+			diag.UnexpectedToken,
+			diag.TokenSpan(p.curToken),
+			"expected a type, got %s", p.curToken.Type,
+		))
 		return nil
 	}
 
-	return identifiers
+	return typ
+}
+
+// parse comma separated types after the current opening token up to end
+func (p *Parser) parseTypeList(end token.TokenType) ([]*ast.TypeAnnotation, bool) {
+	list := []*ast.TypeAnnotation{}
+
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return list, true
+	}
+
+	for {
+		p.nextToken()
+		typ := p.parseType()
+		if typ == nil {
+			return nil, false
+		}
+		list = append(list, typ)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	return list, p.expectPeek(end)
 }
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
@@ -450,10 +1265,42 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	tok := p.curToken
 
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+
+	// slice without a start (a[:end])
+	if p.curTokenIs(token.COLON) {
+		return p.parseSliceExpression(tok, left, nil)
+	}
+
+	index := p.parseExpression(LOWEST)
+
+	// slice with a start (a[start:] or a[start:end])
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(tok, left, index)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return &ast.IndexExpression{Token: tok, Left: left, Index: index, Optional: tok.Type == token.OPTIONAL_LBRACKET}
+}
+
+// parse the remainder of a slice expression, curToken is the colon
+func (p *Parser) parseSliceExpression(tok token.Token, left, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start, Optional: tok.Type == token.OPTIONAL_LBRACKET}
+
+	// end is optional (a[start:])
+	if p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		return exp
+	}
+
+	p.nextToken()
+	exp.End = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
@@ -478,6 +1325,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -495,6 +1343,10 @@ func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.curToken}
+}
+
 // return an identifier for current token
 func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
@@ -521,14 +1373,51 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
+// messages of all diagnostics
 func (p *Parser) Errors() []string {
-	return p.errors
+	msgs := []string{}
+	for _, d := range p.Diagnostics() {
+		msgs = append(msgs, d.Message)
+	}
+	return msgs
+}
+
+// problems found by the lexer and the parser, in source order
+func (p *Parser) Diagnostics() []diag.Diagnostic {
+	diags := append([]diag.Diagnostic{}, p.l.Diagnostics()...)
+	diags = append(diags, p.errors...)
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].Span.Start.Offset < diags[j].Span.Start.Offset
+	})
+	return diags
 }
 
 // add error if type of peekToken doesn't match expectation
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(diag.Errorf(
+		diag.UnexpectedToken,
+		diag.TokenSpan(p.peekToken),
+		"expected next token to be %s, got %s", t, p.peekToken.Type,
+	))
+}
+
+// identifies an error by message and the token it was reported at
+type errorKey struct {
+	tokenIdx int
+	msg      string
+}
+
+// record an error unless it cascades from an earlier one in the same
+// statement or was already reported at this token
+func (p *Parser) addError(d diag.Diagnostic) {
+	key := errorKey{tokenIdx: p.tokenIdx, msg: d.Message}
+	if p.panicking || p.reported[key] {
+		return
+	}
+
+	p.reported[key] = true
+	p.errors = append(p.errors, d)
+	p.panicking = true
 }
 
 // add entries to parser function maps
@@ -3,9 +3,12 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"intInGo/ast"
+	"intInGo/diag"
 	"intInGo/lexer"
+	"strings"
 	"testing"
 )
 
@@ -203,6 +206,68 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "9223372036854775808;"
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("expression not integer literal, got=%T", stmt.Expression)
+	}
+	if literal.Big == nil || literal.Big.String() != "9223372036854775808" {
+		t.Errorf("literal big value not %s, got=%v", "9223372036854775808", literal.Big)
+	}
+	if literal.String() != "9223372036854775808" {
+		t.Errorf("literal string not %s, got=%s", "9223372036854775808", literal.String())
+	}
+}
+
+func TestDecimalLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input string
+		value string
+		scale int
+	}{
+		{"12.50d", "1250", 2},
+		{"7d", "7", 0},
+		{"0.005d", "5", 3},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.DecimalLiteral)
+		if !ok {
+			t.Fatalf("expression not decimal literal, got=%T", stmt.Expression)
+		}
+		if literal.Value.String() != tt.value || literal.Scale != tt.scale {
+			t.Errorf("wrong decimal for %s. want=%s scale %d, got=%s scale %d",
+				tt.input, tt.value, tt.scale, literal.Value, literal.Scale)
+		}
+		if literal.String() != tt.input {
+			t.Errorf("literal string not %s, got=%s", tt.input, literal.String())
+		}
+	}
+
+	p := New(lexer.New("match (x) { -1.50d => 1, 2.5d => 2 }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	match := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+	if got := match.Arms[0].Pattern.String(); got != "-1.50d" {
+		t.Errorf("wrong pattern. want=%q, got=%q", "-1.50d", got)
+	}
+	if got := match.Arms[1].Pattern.String(); got != "2.5d" {
+		t.Errorf("wrong pattern. want=%q, got=%q", "2.5d", got)
+	}
+}
+
 func TestIfExpression(t *testing.T) {
 	input := `if (x < y) { x }`
 
@@ -662,6 +727,373 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 10) { a + b }", "fn(a, b = 10) (a+b)"},
+		{"fn(a, b = a * 2, c = 3) {}", "fn(a, b = (a*2), c = 3) "},
+		{"fn(...rest) { rest }", "fn(...rest) rest"},
+		{"fn(a, b = 1, ...rest) {}", "fn(a, b = 1, ...rest) "},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FunctionLiteral. got=%T", stmt.Expression)
+		}
+		if function.String() != tt.expected {
+			t.Errorf("wrong function. expected=%q, got=%q", tt.expected, function.String())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a = 1, b) {}", "parameter b without default follows parameter with default"},
+		{"fn(...rest, a) {}", "expected next token to be ), got ,"},
+		{"fn(...) {}", "expected next token to be IDENT, got )"},
+		{"fn(1) {}", "expected next token to be IDENT, got INT"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5;", "let x: int = 5;"},
+		{"let xs: array<array<int>> = [];", "let xs: array<array<int>> = [];"},
+		{"let h: hash<string, fn(int, bool): string> = {};", "let h: hash<string, fn(int, bool): string> = {};"},
+		{"let f: fn() = g;", "let f: fn() = g;"},
+		{"fn(x: int, s: string = \"a\", ...r: array<int>): bool { true }", "fn(x: int, s: string = a, ...r: array<int>): bool true"},
+		{"fn(): array<int> { [] }", "fn(): array<int> []"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: = 5;", "expected a type, got ="},
+		{"let x: array<int = 5;", "expected next token to be >, got ="},
+		{"fn(x:) {}", "expected a type, got )"},
+		{"fn(): {}", "expected a type, got {"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestStructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, y }", "struct Point { x, y }"},
+		{"struct Point { x, y, }", "struct Point { x, y }"},
+		{"struct Empty {}", "struct Empty {  }"},
+		{"p.x", "(p.x)"},
+		{"p.x.y + 1", "(((p.x).y)+1)"},
+		{"f(p).x", "(f(p).x)"},
+		{"p.x = 5", "((p.x) = 5)"},
+		{"p.x = q.y = 1 + 2", "((p.x) = ((q.y) = (1+2)))"},
+		{"p.x = fn(a) { a }", "((p.x) = fn(a) a)"},
+		{"arr.push(1).len()", "((arr.push)(1).len)()"},
+		{"-a.len()", "(-(a.len)())"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"struct { x }", "expected next token to be IDENT, got {"},
+		{"struct P { x, x }", "duplicate field x in struct P"},
+		{"struct P { 1 }", "expected next token to be IDENT, got INT"},
+		{"p.1", "expected next token to be IDENT, got INT"},
+		{"x = 5", "cannot assign to x"},
+		{"f() = 5", "cannot assign to f()"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { 1 => a, -2 => b, \"s\" => c, true => d }", "match (x) { 1 => a, -2 => b, \"s\" => c, true => d }"},
+		{"match (x) { _ => 0, }", "match (x) { _ => 0 }"},
+		{"match (x) { n if n > 1 => n * 2 }", "match (x) { n if (n>1) => (n*2) }"},
+		{"match (xs) { [] => 0, [h, ...t] => h, [_, ..._] => 1 }", "match (xs) { [] => 0, [h, ...t] => h, [_, ..._] => 1 }"},
+		{"match (e) { {\"type\": \"x\", \"data\": [d]} => d, {} => 0 }", "match (e) { {\"type\": \"x\", \"data\": [d]} => d, {} => 0 }"},
+		{"match (x) {}", "match (x) {  }"},
+		{"1 + match (x) { _ => 2 }", "(1+match (x) { _ => 2 })"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"match x { _ => 1 }", "expected next token to be (, got IDENT"},
+		{"match (x) { _ 1 }", "expected next token to be =>, got INT"},
+		{"match (x) { a + 1 => 1 }", "expected next token to be =>, got +"},
+		{"match (x) { f(1) => 1 }", "expected next token to be =>, got ("},
+		{"match (x) { [a, a] => 1 }", "a bound more than once in pattern"},
+		{"match (x) { [...t, a] => 1 }", "expected next token to be ], got ,"},
+		{"match (x) { {a: 1} => 1 }", "expected a literal hash key, got IDENT"},
+		{"match (x) { (a) => 1 }", "expected a pattern, got ("},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b, ...rest] = arr;", "let [a, b, ...rest] = arr;"},
+		{"let [_, [x, y]] = arr;", "let [_, [x, y]] = arr;"},
+		{"let {name, age} = person;", "let {name, age} = person;"},
+		{"let {\"name\": n, age,} = person;", "let {\"name\": n, age} = person;"},
+		{"let [a, b]: array<int> = arr;", "let [a, b]: array<int> = arr;"},
+		{"fn([a, b], {x}, c) { a }", "fn([a, b], {x}, c) a"},
+		{"fn([a, b] = [1, 2]) { a }", "fn([a, b] = [1, 2]) a"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("fn([a, b], c) { a }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(fn.Parameters) != 2 || len(fn.Patterns) != 1 || fn.Patterns[fn.Parameters[0]] == nil {
+		t.Errorf("wrong parameters. got=%v, patterns=%v", fn.Parameters, fn.Patterns)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, a] = x;", "a bound more than once in pattern"},
+		{"let {a, \"b\": a} = x;", "a bound more than once in pattern"},
+		{"let [a, 1 + 2] = x;", "expected next token to be ,, got +"},
+		{"let 1 = x;", "expected next token to be IDENT, got INT"},
+		{"fn([a, ...b, c]) {}", "expected next token to be ], got ,"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (x in xs) { puts(x); }", "for (x in xs) puts(x)"},
+		{"for (k, v in h) { k }; 1", "for (k, v in h) k1"},
+		{"for (i in range(10)) { if (i) { break; } continue }", "for (i in range(10)) ifi break;continue;"},
+		{"for (a in xs) { fn() { for (b in a) { break } } }", "for (a in xs) fn() for (b in a) break;"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("for (k, v in h) {}"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.ForStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Key.Value != "k" || stmt.Value.Value != "v" || stmt.Iterable.String() != "h" {
+		t.Errorf("wrong for statement. got=%q", stmt.String())
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "break outside of a loop"},
+		{"for (x in xs) { fn() { continue } }", "continue outside of a loop"},
+		{"for x in xs {}", "expected next token to be (, got IDENT"},
+		{"for (x, y, z in xs) {}", "expected next token to be IN, got ,"},
+		{"for (1 in xs) {}", "expected next token to be IDENT, got INT"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestYieldExpressions(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  string
+		generator []bool // of the function literals in order
+	}{
+		{"fn() { yield 1 + 2 }", "fn() (yield (1+2))", []bool{true}},
+		{"fn() { let x = yield 1; x }", "fn() let x = (yield 1);x", []bool{true}},
+		{"fn() { fn() { yield 1 } }", "fn() fn() (yield 1)", []bool{false, true}},
+		{"fn() { yield fn() { 1 } }", "fn() (yield fn() 1)", []bool{true, false}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. expected=%q, got=%q", tt.expected, program.String())
+		}
+
+		var generator []bool
+		ast.Inspect(program, func(node ast.Node) bool {
+			if fn, ok := node.(*ast.FunctionLiteral); ok {
+				generator = append(generator, fn.Generator)
+			}
+			return true
+		})
+		if fmt.Sprint(generator) != fmt.Sprint(tt.generator) {
+			t.Errorf("wrong generators for %q. expected=%v, got=%v", tt.input, tt.generator, generator)
+		}
+	}
+
+	p := New(lexer.New("yield 1;"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != "yield outside of a function" {
+		t.Errorf("wrong errors. got=%v", p.Errors())
+	}
+}
+
+func TestSelectExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"select { v = recv(a) => v, send(b, 1 + 2) => 0, _ => 1 }", "select { v = recv(a) => v, send(b, (1+2)) => 0, _ => 1 }"},
+		{"select { x = a.recv() => x, b.send(x) => 2, }", "select { x = recv(a) => x, send(b, x) => 2 }"},
+		{"select {}", "select {  }"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"select { f(a) => 1 }", "expected recv(channel) or send(channel, value) in select, got f(a)"},
+		{"select { v = send(a, 1) => 1 }", "expected recv(channel) or send(channel, value) in select, got send(a, 1)"},
+		{"select { recv(a, b) => 1 }", "expected recv(channel) or send(channel, value) in select, got recv(a, b)"},
+		{"select { _ => 1, _ => 2 }", "select has more than one default case"},
+		{"select { recv(a) 1 }", "expected next token to be =>, got INT"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
 func TestArrayLiteralParsing(t *testing.T) {
 	input := "[1, 2 *2, 3 + 3]"
 
@@ -708,6 +1140,81 @@ func TestIndexExpressionParsing(t *testing.T) {
 	}
 }
 
+func TestSliceExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"myArray[1:2]", "(myArray[1:2])"},
+		{"myArray[1:]", "(myArray[1:])"},
+		{"myArray[:2]", "(myArray[:2])"},
+		{"myArray[:]", "(myArray[:])"},
+		{"myArray[-2:a + 1]", "(myArray[(-2):(a+1)])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		sliceExp, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+
+		if !testIdentifier(t, sliceExp.Left, "myArray") {
+			return
+		}
+
+		if sliceExp.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, sliceExp.String())
+		}
+	}
+}
+
+func TestNullExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"null", "null"},
+		{"a ?? b ?? c", "((a??b)??c)"},
+		{"a ?? b == c", "(a??(b==c))"},
+		{"-a ?? b + 1", "((-a)??(b+1))"},
+		{`h?["a"]?["b"] ?? 0`, "(((h?[a]?[b]??0)"},
+		{"a?[1:]", "(a?[1:])"},
+		{"f?(1, 2)(3)", "f?(1, 2)(3)"},
+		{"p.m?()", "(p.m)?()"},
+		{"match (x) { null => 0, _ => 1 }", "match (x) { null => 0, _ => 1 }"},
+		{"let x: null = null", "let x: null = null;"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	program := New(lexer.New(`h?["k"]; f?(); h["k"]; f()`)).ParseProgram()
+	optional := []bool{
+		program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression).Optional,
+		program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression).Optional,
+		program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression).Optional,
+		program.Statements[3].(*ast.ExpressionStatement).Expression.(*ast.CallExpression).Optional,
+	}
+	for i, want := range []bool{true, true, false, false} {
+		if optional[i] != want {
+			t.Errorf("statement %d: wrong Optional. want=%t, got=%t", i, want, optional[i])
+		}
+	}
+}
+
 func TestOperatorPrecedenceParsing(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -858,6 +1365,197 @@ func TestHashLiteralWithExpressionParsing(t *testing.T) {
 	}
 }
 
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+		expectedString string
+	}{
+		{
+			"let x 5; let y = 10; y;",
+			[]string{"expected next token to be =, got INT"},
+			"<bad statement>let y = 10;y",
+		},
+		{
+			") ) ) ); 1",
+			[]string{"no prefix parse function for ) found"},
+			"<bad expression>1",
+		},
+		{
+			"let = 10; let z = (1 + ; z",
+			[]string{
+				"expected next token to be IDENT, got =",
+				"no prefix parse function for ; found",
+			},
+			"<bad statement>let z = <bad expression>;z",
+		},
+		{
+			"if (x { 1 } else { 2 }; 5",
+			[]string{"expected next token to be ), got {"},
+			"<bad expression>5",
+		},
+		{
+			"let f = fn(x) { let = 1; x }; f(1);",
+			[]string{"expected next token to be IDENT, got ="},
+			"let f = fn(x) <bad statement>x;f(1)",
+		},
+		{
+			"let f = fn() { 1 + }; let a = 2; a",
+			[]string{"no prefix parse function for } found"},
+			"let f = fn() (1+<bad expression>);let a = 2;a",
+		},
+		{
+			`let h = {"a" 1}; let y = 2;`,
+			[]string{"expected next token to be :, got INT"},
+			"let h = <bad expression>;let y = 2;",
+		},
+		{
+			"match (1) { 1 2 }; 3",
+			[]string{"expected next token to be =>, got INT"},
+			"<bad expression>3",
+		},
+		{
+			"select { recv(c) as v => v }; 3",
+			[]string{"expected next token to be =>, got IDENT"},
+			"<bad expression>3",
+		},
+		{
+			`let f = fn() { let h = {"a" 1}; h }; f()`,
+			[]string{"expected next token to be :, got INT"},
+			"let f = fn() let h = <bad expression>;h;f()",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("wrong number of errors for %q. want=%q, got=%q", tt.input, tt.expectedErrors, errors)
+			continue
+		}
+		for i, msg := range tt.expectedErrors {
+			if errors[i] != msg {
+				t.Errorf("wrong error %d for %q. want=%q, got=%q", i, tt.input, msg, errors[i])
+			}
+		}
+
+		if program.String() != tt.expectedString {
+			t.Errorf("wrong partial program for %q. want=%q, got=%q", tt.input, tt.expectedString, program.String())
+		}
+	}
+}
+
+func TestParserDiagnostics(t *testing.T) {
+	input := "let x 5;\nlet y = @;\nlet z = );\nlet w = 2 * 1.50;"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	expected := []struct {
+		code    string
+		pos     string
+		message string
+	}{
+		{diag.UnexpectedToken, "1:7", "expected next token to be =, got INT"},
+		{diag.IllegalCharacter, "2:9", "illegal character '@'"},
+		{diag.MissingExpression, "3:9", "no prefix parse function for ) found"},
+		{diag.InvalidInteger, "4:13", "decimal literals need a d suffix, like 1.50d"},
+	}
+
+	diags := p.Diagnostics()
+	if len(diags) != len(expected) {
+		t.Fatalf("wrong number of diagnostics. want=%d, got=%d (%v)", len(expected), len(diags), diags)
+	}
+
+	for i, tt := range expected {
+		d := diags[i]
+		if d.Severity != diag.Error {
+			t.Errorf("diagnostic %d has wrong severity. got=%s", i, d.Severity)
+		}
+		if d.Code != tt.code {
+			t.Errorf("diagnostic %d has wrong code. want=%s, got=%s", i, tt.code, d.Code)
+		}
+		if d.Span.Start.String() != tt.pos {
+			t.Errorf("diagnostic %d has wrong position. want=%s, got=%s", i, tt.pos, d.Span.Start)
+		}
+		if d.Message != tt.message {
+			t.Errorf("diagnostic %d has wrong message. want=%q, got=%q", i, tt.message, d.Message)
+		}
+	}
+}
+
+func TestTracing(t *testing.T) {
+	var out bytes.Buffer
+	p := New(lexer.New("-1 + 2"))
+	p.Trace = &out
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := `BEGIN parseExpressionStatement
+	BEGIN parseExpression
+		BEGIN parsePrefixExpression
+			BEGIN parseExpression
+				BEGIN parseIntegerLiteral
+				END parseIntegerLiteral
+			END parseExpression
+		END parsePrefixExpression
+		BEGIN parseInfixExpression
+			BEGIN parseExpression
+				BEGIN parseIntegerLiteral
+				END parseIntegerLiteral
+			END parseExpression
+		END parseInfixExpression
+	END parseExpression
+END parseExpressionStatement
+`
+	if out.String() != expected {
+		t.Errorf("wrong trace. want=%q, got=%q", expected, out.String())
+	}
+}
+
+// parsers keep their trace state to themselves. run with -race
+func TestConcurrentParsers(t *testing.T) {
+	const parsers = 16
+	traces := make([]bytes.Buffer, parsers)
+	programs := make([]string, parsers)
+	done := make(chan struct{})
+
+	for i := 0; i < parsers; i++ {
+		i := i
+		go func() {
+			defer func() { done <- struct{}{} }()
+			input := strings.Repeat(fmt.Sprintf("let x = -%d * (y + %d);\n", i, i), i+1)
+			p := New(lexer.New(input))
+			p.Trace = &traces[i]
+			programs[i] = p.ParseProgram().String()
+		}()
+	}
+	for i := 0; i < parsers; i++ {
+		<-done
+	}
+
+	for i := 0; i < parsers; i++ {
+		want := strings.Repeat(fmt.Sprintf("let x = ((-%d)*(y+%d));", i, i), i+1)
+		if programs[i] != want {
+			t.Errorf("parser %d: wrong program. want=%q, got=%q", i, want, programs[i])
+		}
+		// every statement's trace starts and ends at the outermost level
+		lines := strings.Split(strings.TrimSuffix(traces[i].String(), "\n"), "\n")
+		if lines[0] != "BEGIN parseExpression" || lines[len(lines)-1] != "END parseExpression" {
+			t.Errorf("parser %d: unbalanced trace %q", i, traces[i].String())
+		}
+		begins := strings.Count(traces[i].String(), "BEGIN ")
+		ends := strings.Count(traces[i].String(), "END ")
+		if begins != ends {
+			t.Errorf("parser %d: %d begins but %d ends", i, begins, ends)
+		}
+	}
+}
+
 // print any parser errors
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
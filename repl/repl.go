@@ -5,18 +5,28 @@ package repl
 import (
 	"bufio"
 	"fmt"
+	"intInGo/diag"
 	"intInGo/evaluator"
 	"intInGo/lexer"
 	"intInGo/object"
 	"intInGo/parser"
+	"intInGo/resolver"
+	"intInGo/types"
 	"io"
+	"strings"
 )
 
 const PROMPT = ">>"
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+// run the repl, fileRoot is the directory the file builtins are confined
+// to, or empty to disable them
+func Start(in io.Reader, out io.Writer, fileRoot string) {
+	// share one buffered reader with the program, so builtins like input()
+	// don't lose what the repl has already buffered and vice versa
+	reader := bufio.NewReader(in)
+	ctx := object.NewContext(reader, out, out)
+	ctx.FileRoot = fileRoot
+	env := object.NewEnvironmentWithContext(ctx)
 
 	io.WriteString(out, MONKE)
 
@@ -24,24 +34,47 @@ func Start(in io.Reader, out io.Writer) {
 		fmt.Fprintf(out, PROMPT)
 
 		// read from input source until hitting newline
-		scanned := scanner.Scan()
-		if !scanned {
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
 			return
 		}
 
 		// instantiate lexer with just read line
-		line := scanner.Text()
+		line = strings.TrimRight(line, "\r\n")
 		l := lexer.New(line)
 
 		// parse that line
 		p := parser.New(l)
 		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if len(p.Diagnostics()) != 0 {
+			printParserErrors(out, line, p.Diagnostics())
+			continue
+		}
+
+		// catch misspelled names before running anything. names
+		// used in functions may still be defined by later lines
+		resolved := resolver.Resolve(program, resolver.Options{
+			Globals:     append(env.Names(), evaluator.BuiltinNames()...),
+			Incremental: true,
+		})
+		// and operations that can't work with the types of the values
+		globals := map[string]types.Type{}
+		for _, name := range env.Names() {
+			if obj, ok := env.Get(name); ok {
+				globals[name] = types.Of(obj)
+			}
+		}
+		checked := types.Check(program, types.Options{Globals: globals})
+
+		if reportDiagnostics(out, line, append(resolved.Diagnostics, checked.Diagnostics...)) {
 			continue
 		}
 
 		evaluated := evaluator.Eval(program, env)
+		if err, ok := evaluated.(*object.Error); ok {
+			diag.Render(out, "", line, err.Diagnostic())
+			continue
+		}
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -49,13 +82,23 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
+func printParserErrors(out io.Writer, source string, diags []diag.Diagnostic) {
 	io.WriteString(out, MONKE)
 	io.WriteString(out, "We ran into some monkey business here!\n")
 	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, d := range diags {
+		diag.Render(out, "", source, d)
+	}
+}
+
+// render diagnostics, telling whether any of them is an error
+func reportDiagnostics(out io.Writer, source string, diags []diag.Diagnostic) bool {
+	failed := false
+	for _, d := range diags {
+		diag.Render(out, "", source, d)
+		failed = failed || d.Severity == diag.Error
 	}
+	return failed
 }
 
 const MONKE = `            __,__
@@ -0,0 +1,364 @@
+// resolver/resolver.go
+
+package resolver
+
+import (
+	"intInGo/ast"
+	"intInGo/diag"
+	"sort"
+	"strings"
+)
+
+// where a name lives at runtime: Depth environments out from the one the
+// identifier is evaluated in, at Slot of that environment
+type Binding struct {
+	Depth int
+	Slot  int
+}
+
+type Options struct {
+	Globals []string // names defined before the program runs, like builtins
+
+	// unresolved names inside function bodies are only warnings, input
+	// that comes later may still define them before the function runs
+	Incremental bool
+}
+
+type Result struct {
+	// binding of every identifier that names a let or a parameter,
+	// definitions and uses alike. globals from Options have none
+	Bindings map[*ast.Identifier]Binding
+
+	// identifier of the let or parameter each use refers to
+	Definitions map[*ast.Identifier]*ast.Identifier
+
+	Diagnostics []diag.Diagnostic
+}
+
+// a let binding or parameter
+type definition struct {
+	name  *ast.Identifier
+	slot  int
+	param bool
+	used  bool
+}
+
+// names of the program, a function body or a match arm, mirroring the
+// environments the evaluator creates. blocks of if expressions share the
+// scope they are in, like they share the environment
+type scope struct {
+	outer    *scope
+	names    map[string]*definition // current definition of each name
+	defs     []*definition          // all definitions, including replaced ones
+	slots    map[string]int
+	global   bool
+	function bool // scope of a function body
+	pending  []*ast.FunctionLiteral
+}
+
+type resolver struct {
+	opts    Options
+	globals map[string]bool
+	scope   *scope
+	result  *Result
+}
+
+// resolve every identifier of a program to its binding, reporting names
+// that aren't defined, lets that are never used, lets shadowing a
+// parameter and duplicate parameters
+func Resolve(program *ast.Program, opts Options) *Result {
+	r := &resolver{
+		opts:    opts,
+		globals: map[string]bool{},
+		result: &Result{
+			Bindings:    map[*ast.Identifier]Binding{},
+			Definitions: map[*ast.Identifier]*ast.Identifier{},
+		},
+	}
+	for _, name := range opts.Globals {
+		r.globals[name] = true
+	}
+
+	r.scope = newScope(nil)
+	r.scope.global = true
+	r.statements(program.Statements)
+	r.finish()
+
+	sort.SliceStable(r.result.Diagnostics, func(i, j int) bool {
+		return r.result.Diagnostics[i].Span.Start.Offset < r.result.Diagnostics[j].Span.Start.Offset
+	})
+	return r.result
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, names: map[string]*definition{}, slots: map[string]int{}}
+}
+
+// function bodies run only once the code around them is done, so they're
+// resolved at the end of their scope and see all of its bindings
+func (r *resolver) finish() {
+	for i := 0; i < len(r.scope.pending); i++ {
+		r.body(r.scope.pending[i])
+	}
+
+	if r.scope.global {
+		return
+	}
+	for _, d := range r.scope.defs {
+		if !d.used && !d.param && !strings.HasPrefix(d.name.Value, "_") {
+			r.report(diag.Warningf(diag.UnusedBinding, diag.TokenSpan(d.name.Token),
+				"%s declared and not used", d.name.Value))
+		}
+	}
+}
+
+func (s *scope) inFunction() bool {
+	for ; s != nil; s = s.outer {
+		if s.function {
+			return true
+		}
+	}
+	return false
+}
+
+// parameter of an enclosing function still visible under name
+func (s *scope) param(name string) *definition {
+	for ; s != nil; s = s.outer {
+		if d, ok := s.names[name]; ok {
+			if d.param {
+				return d
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *resolver) report(d diag.Diagnostic) {
+	r.result.Diagnostics = append(r.result.Diagnostics, d)
+}
+
+func (r *resolver) define(name *ast.Identifier, param bool) *definition {
+	slot, ok := r.scope.slots[name.Value]
+	if !ok {
+		slot = len(r.scope.slots)
+		r.scope.slots[name.Value] = slot
+	}
+
+	d := &definition{name: name, slot: slot, param: param}
+	r.scope.names[name.Value] = d
+	r.scope.defs = append(r.scope.defs, d)
+	r.result.Bindings[name] = Binding{Depth: 0, Slot: slot}
+	return d
+}
+
+func (r *resolver) use(ident *ast.Identifier) {
+	depth := 0
+	for s := r.scope; s != nil; s = s.outer {
+		if d, ok := s.names[ident.Value]; ok {
+			d.used = true
+			r.result.Bindings[ident] = Binding{Depth: depth, Slot: d.slot}
+			r.result.Definitions[ident] = d.name
+			return
+		}
+		depth++
+	}
+
+	if r.globals[ident.Value] {
+		return
+	}
+
+	d := diag.Errorf(diag.UndefinedName, diag.TokenSpan(ident.Token), "identifier not found: %s", ident.Value)
+	if r.opts.Incremental && r.scope.inFunction() {
+		d.Severity = diag.Warning
+	}
+	r.report(d)
+}
+
+func (r *resolver) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		r.statement(stmt)
+	}
+}
+
+func (r *resolver) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		// the value is evaluated before the name is bound
+		r.expr(stmt.Value)
+		names := []*ast.Identifier{stmt.Name}
+		if stmt.Pattern != nil {
+			names = ast.PatternNames(stmt.Pattern)
+		} else if stmt.Name == nil {
+			return
+		}
+		for _, name := range names {
+			if prev, ok := r.scope.names[name.Value]; ok && prev.param {
+				d := diag.Warningf(diag.ShadowedParameter, diag.TokenSpan(name.Token),
+					"let %s shadows parameter %s", name.Value, name.Value)
+				d.Notes = []string{"parameter declared at " + prev.name.Token.Pos.String()}
+				r.report(d)
+			}
+			r.define(name, false)
+		}
+	case *ast.ReturnStatement:
+		r.expr(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		r.expr(stmt.Expression)
+	case *ast.BlockStatement:
+		r.block(stmt)
+	case *ast.StructStatement:
+		if stmt.Name != nil {
+			r.define(stmt.Name, false)
+		}
+	case *ast.ForStatement:
+		// like if blocks the body runs in the environment of the loop,
+		// which the loop variables are set in
+		r.expr(stmt.Iterable)
+		if stmt.Key != nil {
+			r.define(stmt.Key, false)
+		}
+		r.define(stmt.Value, false)
+		r.block(stmt.Body)
+	}
+}
+
+func (r *resolver) block(block *ast.BlockStatement) {
+	if block != nil {
+		r.statements(block.Statements)
+	}
+}
+
+func (r *resolver) expr(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		r.use(exp)
+	case *ast.PrefixExpression:
+		r.expr(exp.Right)
+	case *ast.InfixExpression:
+		r.expr(exp.Left)
+		r.expr(exp.Right)
+	case *ast.IfExpression:
+		r.expr(exp.Condition)
+		r.block(exp.Consequence)
+		r.block(exp.Alternative)
+	case *ast.FunctionLiteral:
+		r.scope.pending = append(r.scope.pending, exp)
+	case *ast.CallExpression:
+		r.expr(exp.Function)
+		for _, arg := range exp.Arguments {
+			r.expr(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			r.expr(el)
+		}
+	case *ast.HashLiteral:
+		for _, key := range exp.Keys {
+			r.expr(key)
+			r.expr(exp.Pairs[key])
+		}
+	case *ast.IndexExpression:
+		r.expr(exp.Left)
+		r.expr(exp.Index)
+	case *ast.SliceExpression:
+		r.expr(exp.Left)
+		r.expr(exp.Start)
+		r.expr(exp.End)
+	case *ast.MemberExpression:
+		// fields are looked up on the value, not in scope
+		r.expr(exp.Object)
+	case *ast.AssignExpression:
+		r.expr(exp.Target)
+		r.expr(exp.Value)
+	case *ast.YieldExpression:
+		r.expr(exp.Value)
+	case *ast.SelectExpression:
+		for _, c := range exp.Cases {
+			r.expr(c.Channel)
+			r.expr(c.Value)
+		}
+		for _, c := range exp.Cases {
+			r.selectCase(c)
+		}
+	case *ast.MatchExpression:
+		r.expr(exp.Subject)
+		for _, arm := range exp.Arms {
+			r.arm(arm)
+		}
+	}
+}
+
+// an arm binds the names of its pattern in an environment of its own
+func (r *resolver) arm(arm *ast.MatchArm) {
+	outer := r.scope
+	r.scope = newScope(outer)
+
+	for _, name := range ast.PatternNames(arm.Pattern) {
+		r.define(name, false)
+	}
+	r.expr(arm.Guard)
+	r.expr(arm.Body)
+
+	r.finish()
+	r.scope = outer
+}
+
+// like a match arm the body of a select case gets an environment of its
+// own, holding the received value
+func (r *resolver) selectCase(c *ast.SelectCase) {
+	outer := r.scope
+	r.scope = newScope(outer)
+
+	if c.Name != nil {
+		r.define(c.Name, false)
+	}
+	r.expr(c.Body)
+
+	r.finish()
+	r.scope = outer
+}
+
+func (r *resolver) body(fn *ast.FunctionLiteral) {
+	outer := r.scope
+	r.scope = newScope(outer)
+	r.scope.function = true
+
+	for _, param := range fn.Parameters {
+		// defaults are evaluated before their parameter is bound
+		if def, ok := fn.Defaults[param]; ok {
+			r.expr(def)
+		}
+		if pattern, ok := fn.Patterns[param]; ok {
+			for _, name := range ast.PatternNames(pattern) {
+				r.param(name, outer)
+			}
+			continue
+		}
+		r.param(param, outer)
+	}
+	if fn.Rest != nil {
+		r.param(fn.Rest, outer)
+	}
+
+	if fn.Body != nil {
+		r.statements(fn.Body.Statements)
+	}
+	r.finish()
+	r.scope = outer
+}
+
+func (r *resolver) param(param *ast.Identifier, outer *scope) {
+	if prev, ok := r.scope.names[param.Value]; ok {
+		d := diag.Errorf(diag.DuplicateParameter, diag.TokenSpan(param.Token),
+			"duplicate parameter %s", param.Value)
+		d.Notes = []string{"first declared at " + prev.name.Token.Pos.String()}
+		r.report(d)
+	} else if prev := outer.param(param.Value); prev != nil {
+		d := diag.Warningf(diag.ShadowedParameter, diag.TokenSpan(param.Token),
+			"parameter %s shadows parameter of enclosing function", param.Value)
+		d.Notes = []string{"enclosing parameter declared at " + prev.name.Token.Pos.String()}
+		r.report(d)
+	}
+	r.define(param, true)
+}
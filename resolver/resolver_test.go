@@ -0,0 +1,133 @@
+// resolver/resolver_test.go
+
+package resolver
+
+import (
+	"intInGo/ast"
+	"intInGo/diag"
+	"intInGo/lexer"
+	"intInGo/parser"
+	"testing"
+)
+
+func resolve(t *testing.T, input string, opts Options) (*ast.Program, *Result) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program, Resolve(program, opts)
+}
+
+func TestResolveDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x", nil},
+		{"len([1])", nil},
+		{"x", []string{"1:1: error[E0203]: identifier not found: x"}},
+		{"let x = x + 1;", []string{"1:9: error[E0203]: identifier not found: x"}},
+		{"x; let x = 1;", []string{"1:1: error[E0203]: identifier not found: x"}},
+		// bodies run later, so they see bindings made after them
+		{"let f = fn() { g() }; let g = fn() { f() };", nil},
+		{"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) } };", nil},
+		{"let f = fn() { y };", []string{"1:16: error[E0203]: identifier not found: y"}},
+		{"let f = fn(x) { let y = 2; let _z = 3; x };", []string{"1:21: warning[E0301]: y declared and not used"}},
+		{"let f = fn() { let y = 1; let y = y + 1; };", []string{"1:31: warning[E0301]: y declared and not used"}},
+		{"if (true) { let y = 1; }; y", nil},
+		{"let f = fn(a, b, a) { a + b };", []string{"1:18: error[E0300]: duplicate parameter a"}},
+		{"let f = fn(a) { let a = 2; a };", []string{"1:21: warning[E0302]: let a shadows parameter a"}},
+		{"let f = fn(a) { fn(a) { a } };", []string{"1:20: warning[E0302]: parameter a shadows parameter of enclosing function"}},
+		{"let a = 1; let f = fn(a) { a };", nil},
+		{"let f = fn(a, b = a + 1, ...rest) { [b, rest] };", nil},
+		{"let f = fn(a = b, b = 1) { a };", []string{"1:16: error[E0203]: identifier not found: b"}},
+		{"let f = fn(a, ...a) { a };", []string{"1:18: error[E0300]: duplicate parameter a"}},
+		{"let f = fn(a) { let g = fn() { let a = 1; a }; g };", nil},
+		{"match ([1]) { [h, ...t] if h > 0 => t, n => n, _ => 0 }", nil},
+		{"match (1) { n => 1, [_, ..._t] => 2 }", []string{"1:13: warning[E0301]: n declared and not used"}},
+		{"match (1) { n => 1 }; n", []string{"1:13: warning[E0301]: n declared and not used", "1:23: error[E0203]: identifier not found: n"}},
+		{"match (1) { n => fn() { n } }", nil},
+		{"let [a, ...b] = [1]; let {c} = {}; [a, b, c]", nil},
+		{"let f = fn() { let [a, b] = [1, 2]; a };", []string{"1:24: warning[E0301]: b declared and not used"}},
+		{"let f = fn([a, b], {a}) { b };", []string{"1:21: error[E0300]: duplicate parameter a"}},
+		{"let f = fn([a, b]) { let {a} = {}; a + b };", []string{"1:27: warning[E0302]: let a shadows parameter a"}},
+		{"let f = fn([a, b] = [c, 1]) { a + b };", []string{"1:22: error[E0203]: identifier not found: c"}},
+		{"for (x in [1]) { x }; x", nil},
+		{"for (k, v in xs) { v }", []string{"1:14: error[E0203]: identifier not found: xs"}},
+		{"for (x in [x]) {}", []string{"1:12: error[E0203]: identifier not found: x"}},
+		{"let f = fn() { for (i, x in [1]) { x } };", []string{"1:21: warning[E0301]: i declared and not used"}},
+		{"let f = fn() { for (_i, x in [1]) { x } };", nil},
+		{"let g = fn() { yield y };", []string{"1:22: error[E0203]: identifier not found: y"}},
+		{"let c = 1; select { v = recv(c) => v, send(c, v) => 1 }", []string{"1:47: error[E0203]: identifier not found: v"}},
+		{"let c = 1; select { v = recv(c) => 1 }", []string{"1:21: warning[E0301]: v declared and not used"}},
+	}
+
+	for _, tt := range tests {
+		_, result := resolve(t, tt.input, Options{Globals: []string{"len"}})
+
+		if len(result.Diagnostics) != len(tt.expected) {
+			t.Errorf("wrong number of diagnostics for %q. want=%d, got=%d (%v)",
+				tt.input, len(tt.expected), len(result.Diagnostics), result.Diagnostics)
+			continue
+		}
+		for i, d := range result.Diagnostics {
+			if d.String() != tt.expected[i] {
+				t.Errorf("wrong diagnostic for %q.\nwant=%s\ngot= %s", tt.input, tt.expected[i], d)
+			}
+		}
+	}
+}
+
+func TestIncremental(t *testing.T) {
+	_, result := resolve(t, "let f = fn() { later() }; now()", Options{Incremental: true})
+
+	if len(result.Diagnostics) != 2 {
+		t.Fatalf("wrong number of diagnostics. want=2, got=%d", len(result.Diagnostics))
+	}
+	if result.Diagnostics[0].Severity != diag.Warning {
+		t.Errorf("name inside a function should only warn. got=%s", result.Diagnostics[0].Severity)
+	}
+	if result.Diagnostics[1].Severity != diag.Error {
+		t.Errorf("name at the top level should be an error. got=%s", result.Diagnostics[1].Severity)
+	}
+}
+
+func TestBindings(t *testing.T) {
+	input := `let a = 1;
+let b = 2;
+let f = fn(x, y) {
+  let z = x;
+  fn() { z + y + b }
+};
+let a = 3;`
+
+	_, result := resolve(t, input, Options{})
+
+	expected := map[string]Binding{
+		"1:5":  {0, 0}, // a
+		"2:5":  {0, 1}, // b
+		"3:5":  {0, 2}, // f
+		"3:12": {0, 0}, // x
+		"3:15": {0, 1}, // y
+		"4:7":  {0, 2}, // z
+		"4:11": {0, 0}, // x used in f
+		"5:10": {1, 2}, // z used in the inner function
+		"5:14": {1, 1}, // y
+		"5:18": {2, 1}, // b
+		"7:5":  {0, 0}, // a again reuses its slot
+	}
+
+	got := map[string]Binding{}
+	for ident, b := range result.Bindings {
+		got[ident.Token.Pos.String()] = b
+	}
+	for pos, want := range expected {
+		if b, ok := got[pos]; !ok || b != want {
+			t.Errorf("wrong binding at %s. want=%+v, got=%+v (found=%t)", pos, want, b, ok)
+		}
+	}
+	if len(got) != len(expected) {
+		t.Errorf("wrong number of bindings. want=%d, got=%d", len(expected), len(got))
+	}
+}
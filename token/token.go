@@ -2,11 +2,29 @@
 
 package token
 
+import "strconv"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // first character of the token
+	End     Position // just past the last character of the token
+}
+
+// location in the source, lines and columns start at 1
+type Position struct {
+	Offset int // byte offset, starting at 0
+	Line   int
+	Column int
+}
+
+// positions of tokens not produced by the lexer are left zero
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
 }
 
 const (
@@ -14,8 +32,9 @@ const (
 	EOF     = "EOF"     // tells parser to stop
 
 	// Identifiers and literals
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT   = "IDENT"
+	INT     = "INT"
+	DECIMAL = "DECIMAL" // 12.50d
 
 	// Operators
 	ASSIGN   = "="
@@ -28,6 +47,8 @@ const (
 	LT = "<"
 	GT = ">"
 
+	NULLISH = "??" // a ?? b, b if a is null
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -38,6 +59,13 @@ const (
 	LBRACKET  = "["
 	RBRACKET  = "]"
 	COLON     = ":"
+	ELLIPSIS  = "..."
+	DOT       = "."
+	ARROW     = "=>"
+
+	// optional index and call, null if the indexed or called value is
+	OPTIONAL_LBRACKET = "?["
+	OPTIONAL_LPAREN   = "?("
 
 	// Keywords
 	FUNCTION = "FUNCTION"
@@ -47,21 +75,42 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	STRUCT   = "STRUCT"
+	MATCH    = "MATCH"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	YIELD    = "YIELD"
+	SELECT   = "SELECT"
+	NULL     = "NULL"
 
 	EQ     = "=="
 	NOT_EQ = "!="
 
 	STRING = "STRING"
+
+	// line comments (// ...), kept aside by the lexer rather than returned
+	COMMENT = "COMMENT"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"struct":   STRUCT,
+	"match":    MATCH,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"yield":    YIELD,
+	"select":   SELECT,
+	"null":     NULL,
 }
 
 // check whether given identifier is actually a keyword
@@ -0,0 +1,951 @@
+// types/check.go
+
+package types
+
+import (
+	"intInGo/ast"
+	"intInGo/diag"
+	"intInGo/token"
+	"sort"
+	"strconv"
+)
+
+type Options struct {
+	Globals map[string]Type // names defined before the program runs
+}
+
+type Result struct {
+	Types       map[ast.Expression]Type // inferred type of every checked expression
+	Diagnostics []diag.Diagnostic
+}
+
+// signatures of the builtins
+var builtins = map[string]Type{
+	"len":            &Func{Params: []Type{Dynamic}, Result: Int},
+	"first":          &Func{Params: []Type{Dynamic}, Result: Dynamic},
+	"last":           &Func{Params: []Type{Dynamic}, Result: Dynamic},
+	"rest":           &Func{Params: []Type{Dynamic}, Result: Dynamic},
+	"push":           &Func{Params: []Type{Dynamic, Dynamic}, Result: Dynamic},
+	"puts":           &Func{Rest: Dynamic, Result: Null},
+	"print":          &Func{Rest: Dynamic, Result: Null},
+	"eprint":         &Func{Rest: Dynamic, Result: Null},
+	"format":         &Func{Params: []Type{String}, Rest: Dynamic, Result: String},
+	"input":          &Func{Params: []Type{Dynamic}, Optional: 1, Result: Dynamic},
+	"json_parse":     &Func{Params: []Type{String}, Result: Dynamic},
+	"json_stringify": &Func{Params: []Type{Dynamic, Dynamic}, Optional: 1, Result: String},
+	"read_file":      &Func{Params: []Type{String}, Result: String},
+	"write_file":     &Func{Params: []Type{String, String}, Result: Null},
+	"append_file":    &Func{Params: []Type{String, String}, Result: Null},
+	"exists":         &Func{Params: []Type{String}, Result: Bool},
+	"list_dir":       &Func{Params: []Type{String}, Result: &Array{Elem: String}},
+	"range":          &Func{Params: []Type{Int, Int, Int}, Optional: 2, Result: Range},
+	"iter":           &Func{Params: []Type{Dynamic}, Result: Iterator},
+	"spawn":          &Func{Params: []Type{Dynamic}, Rest: Dynamic, Result: Task},
+	"await":          &Func{Params: []Type{Task}, Result: Dynamic},
+	"channel":        &Func{Params: []Type{Int}, Optional: 1, Result: Channel},
+	"send":           &Func{Params: []Type{Channel, Dynamic}, Result: Null},
+	"recv":           &Func{Params: []Type{Channel}, Result: Dynamic},
+	"close":          &Func{Params: []Type{Channel}, Result: Null},
+	"decimal":        &Func{Params: []Type{Dynamic, Int}, Optional: 1, Result: Decimal},
+	"round":          &Func{Params: []Type{Dynamic, Int, String}, Optional: 2, Result: Decimal},
+	"int":            &Func{Params: []Type{Dynamic}, Result: Int},
+	"is_null":        &Func{Params: []Type{Dynamic}, Result: Bool},
+}
+
+// signatures of the methods of the built-in types by kind, without the
+// receiver. the array and hash methods don't know their element types
+var methods = map[string]map[string]*Func{
+	"string": {
+		"len":         {Result: Int},
+		"upper":       {Result: String},
+		"lower":       {Result: String},
+		"trim":        {Result: String},
+		"split":       {Params: []Type{String}, Result: &Array{Elem: String}},
+		"contains":    {Params: []Type{String}, Result: Bool},
+		"starts_with": {Params: []Type{String}, Result: Bool},
+		"ends_with":   {Params: []Type{String}, Result: Bool},
+		"replace":     {Params: []Type{String, String}, Result: String},
+	},
+	"array": {
+		"join":     {Params: []Type{String}, Result: String},
+		"contains": {Params: []Type{Dynamic}, Result: Bool},
+		"index_of": {Params: []Type{Dynamic}, Result: Int},
+		"reverse":  {Result: Dynamic},
+	},
+	"hash": {
+		"len":    {Result: Int},
+		"keys":   {Result: Dynamic},
+		"values": {Result: Dynamic},
+		"has":    {Params: []Type{Dynamic}, Result: Bool},
+	},
+	"iterator": {
+		"next":    {Result: &Hash{Key: String, Value: Dynamic}},
+		"collect": {Result: &Array{Elem: Dynamic}},
+	},
+}
+
+// a binding and whether it was bound again with another type
+type variable struct {
+	typ     Type
+	changed bool
+}
+
+// names of the program or a function body, like the environments of
+// the evaluator. blocks of if expressions share the scope they're in
+type scope struct {
+	outer   *scope
+	vars    map[string]variable
+	fn      *Func // function the scope is the body of, nil at the top
+	pending []pendingBody
+}
+
+type pendingBody struct {
+	lit *ast.FunctionLiteral
+	typ *Func
+}
+
+type checker struct {
+	opts   Options
+	scope  *scope
+	result *Result
+}
+
+// infer the types of a program and report the operations that would
+// fail at runtime whatever the dynamic values turn out to be
+func Check(program *ast.Program, opts Options) *Result {
+	c := &checker{
+		opts:   opts,
+		result: &Result{Types: map[ast.Expression]Type{}},
+		scope:  &scope{vars: map[string]variable{}},
+	}
+
+	c.statements(program.Statements)
+	c.finish()
+
+	sort.SliceStable(c.result.Diagnostics, func(i, j int) bool {
+		return c.result.Diagnostics[i].Span.Start.Offset < c.result.Diagnostics[j].Span.Start.Offset
+	})
+	return c.result
+}
+
+func (c *checker) errorf(code string, tok token.Token, format string, a ...interface{}) {
+	c.result.Diagnostics = append(c.result.Diagnostics, diag.Errorf(code, diag.TokenSpan(tok), format, a...))
+}
+
+// check the bodies of the functions of the scope, they only run once
+// the code around them is done
+func (c *checker) finish() {
+	for i := 0; i < len(c.scope.pending); i++ {
+		c.body(c.scope.pending[i])
+	}
+}
+
+func (c *checker) define(name string, typ Type) {
+	if typ == nil {
+		typ = Dynamic
+	}
+	v, ok := c.scope.vars[name]
+	c.scope.vars[name] = variable{typ: typ, changed: v.changed || (ok && !Identical(v.typ, typ))}
+}
+
+func (c *checker) lookup(name string) Type {
+	for s := c.scope; s != nil; s = s.outer {
+		if v, ok := s.vars[name]; ok {
+			// a function body sees the binding at the time it's called
+			if s != c.scope && v.changed {
+				return Dynamic
+			}
+			return v.typ
+		}
+	}
+	if typ, ok := c.opts.Globals[name]; ok {
+		return typ
+	}
+	if typ, ok := builtins[name]; ok {
+		return typ
+	}
+	return Dynamic
+}
+
+// type of the value of a list of statements, nil when it always returns
+func (c *checker) statements(stmts []ast.Statement) Type {
+	var typ Type = Null
+	for _, stmt := range stmts {
+		typ = c.statement(stmt)
+	}
+	return typ
+}
+
+func (c *checker) statement(stmt ast.Statement) Type {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		value := c.expr(stmt.Value)
+		if stmt.Pattern != nil {
+			if stmt.Type != nil {
+				declared := c.annotation(stmt.Type)
+				if !c.assignable(stmt.Value, value, declared) {
+					c.errorf(diag.IncompatibleTypes, stmt.Token, "cannot use %s as %s in let %s", value, declared, stmt.Pattern)
+				}
+				value = declared
+			}
+			c.pattern(stmt.Pattern, value, true)
+			return Dynamic
+		}
+		if stmt.Name == nil {
+			return Dynamic
+		}
+		if stmt.Type != nil {
+			declared := c.annotation(stmt.Type)
+			if !c.assignable(stmt.Value, value, declared) {
+				c.errorf(diag.IncompatibleTypes, stmt.Name.Token, "cannot use %s as %s in let %s", value, declared, stmt.Name.Value)
+			}
+			value = declared
+		}
+		c.define(stmt.Name.Value, value)
+		return Dynamic
+
+	case *ast.ReturnStatement:
+		value := c.expr(stmt.ReturnValue)
+		if fn := c.scope.fn; fn != nil && !c.assignable(stmt.ReturnValue, value, fn.Result) {
+			c.errorf(diag.IncompatibleTypes, stmt.Token, "cannot return %s from function returning %s", value, fn.Result)
+		}
+		return nil
+
+	case *ast.ExpressionStatement:
+		return c.expr(stmt.Expression)
+
+	case *ast.BlockStatement:
+		return c.statements(stmt.Statements)
+
+	case *ast.StructStatement:
+		if stmt.Name == nil {
+			return Dynamic
+		}
+		st := &Struct{Name: stmt.Name.Value}
+		for _, field := range stmt.Fields {
+			st.Fields = append(st.Fields, field.Value)
+		}
+		c.define(stmt.Name.Value, constructor(st))
+		return Dynamic
+
+	case *ast.ForStatement:
+		c.loop(stmt)
+		return Dynamic
+	}
+	return Dynamic
+}
+
+// type of a select expression, joining the types of the bodies of its
+// cases. each case gets a scope of its own for the received value
+func (c *checker) selectCases(exp *ast.SelectExpression) Type {
+	var typ Type
+	for _, sc := range exp.Cases {
+		if sc.Channel != nil {
+			if ch := c.expr(sc.Channel); !Assignable(ch, Channel) {
+				c.errorf(diag.IncompatibleTypes, sc.Op, "cannot %s on %s, not a channel", sc.Op.Literal, ch)
+			}
+		}
+		if sc.Value != nil {
+			c.expr(sc.Value)
+		}
+
+		outer := c.scope
+		c.scope = &scope{outer: outer, vars: map[string]variable{}, fn: outer.fn}
+		if sc.Name != nil {
+			c.define(sc.Name.Value, Dynamic)
+		}
+		typ = Join(typ, c.expr(sc.Body))
+		c.finish()
+		c.scope = outer
+	}
+	if typ == nil {
+		typ = Dynamic
+	}
+	return typ
+}
+
+// check a for loop. the body may run any number of times, so like an if
+// without else the bindings it makes are joined with those before it
+func (c *checker) loop(stmt *ast.ForStatement) {
+	iterable := c.expr(stmt.Iterable)
+
+	var key, value Type = Dynamic, Dynamic
+	switch t := iterable.(type) {
+	case *Array:
+		key, value = Int, t.Elem
+	case *Hash:
+		key, value = t.Key, t.Value
+		if stmt.Key == nil {
+			value = t.Key
+		}
+	case *Func, *Struct:
+		c.errorf(diag.NotIterable, stmt.Token, "cannot iterate over %s", iterable)
+	default:
+		switch iterable {
+		case String:
+			key, value = Int, String
+		case Range:
+			key, value = Int, Int
+		case Iterator, Channel:
+			key = Int
+		case Int, Decimal, Bool, Null:
+			c.errorf(diag.NotIterable, stmt.Token, "cannot iterate over %s", iterable)
+		}
+	}
+
+	if stmt.Key != nil {
+		c.define(stmt.Key.Value, key)
+	}
+	c.define(stmt.Value.Value, value)
+	c.branches(stmt.Body, nil)
+}
+
+// check the blocks of an if expression, each starting from the bindings
+// before it. names bound differently by the branches become dynamic
+func (c *checker) branches(blocks ...*ast.BlockStatement) Type {
+	before := c.scope.vars
+	var after []map[string]variable
+	var typ Type
+
+	for _, block := range blocks {
+		c.scope.vars = copyVars(before)
+		if block == nil {
+			typ = Join(typ, Null)
+		} else {
+			typ = Join(typ, c.statements(block.Statements))
+		}
+		after = append(after, c.scope.vars)
+	}
+
+	merged := map[string]variable{}
+	for _, vars := range after {
+		for name, v := range vars {
+			if prev, ok := merged[name]; ok {
+				v = variable{typ: Join(prev.typ, v.typ), changed: prev.changed || v.changed || !Identical(prev.typ, v.typ)}
+			}
+			merged[name] = v
+		}
+	}
+	c.scope.vars = merged
+	return typ
+}
+
+func copyVars(vars map[string]variable) map[string]variable {
+	vs := make(map[string]variable, len(vars))
+	for name, v := range vars {
+		vs[name] = v
+	}
+	return vs
+}
+
+// whether exp, of type value, can be used as target. literals mixing
+// types have dynamic elements, which are assignable to anything, so the
+// elements of literals are checked one by one
+func (c *checker) assignable(exp ast.Expression, value, target Type) bool {
+	switch lit := exp.(type) {
+	case *ast.ArrayLiteral:
+		if target, ok := target.(*Array); ok {
+			for _, el := range lit.Elements {
+				if !c.assignable(el, c.result.Types[el], target.Elem) {
+					return false
+				}
+			}
+			return true
+		}
+	case *ast.HashLiteral:
+		if target, ok := target.(*Hash); ok {
+			for _, key := range lit.Keys {
+				val := lit.Pairs[key]
+				if !c.assignable(key, c.result.Types[key], target.Key) ||
+					!c.assignable(val, c.result.Types[val], target.Value) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return Assignable(value, target)
+}
+
+func (c *checker) expr(exp ast.Expression) Type {
+	if exp == nil {
+		return Dynamic
+	}
+	typ := c.infer(exp)
+	if typ == nil {
+		typ = Dynamic
+	}
+	c.result.Types[exp] = typ
+	return typ
+}
+
+func (c *checker) infer(exp ast.Expression) Type {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.DecimalLiteral:
+		return Decimal
+	case *ast.StringLiteral:
+		return String
+	case *ast.Boolean:
+		return Bool
+	case *ast.NullLiteral:
+		return Null
+	case *ast.Identifier:
+		return c.lookup(exp.Value)
+	case *ast.PrefixExpression:
+		return c.prefix(exp)
+	case *ast.InfixExpression:
+		return c.infix(exp)
+	case *ast.IfExpression:
+		c.expr(exp.Condition)
+		if exp.Alternative == nil {
+			return c.branches(exp.Consequence, nil)
+		}
+		return c.branches(exp.Consequence, exp.Alternative)
+	case *ast.ArrayLiteral:
+		var elem Type
+		for _, el := range exp.Elements {
+			elem = Join(elem, c.expr(el))
+		}
+		if elem == nil {
+			elem = Dynamic
+		}
+		return &Array{Elem: elem}
+	case *ast.HashLiteral:
+		var key, value Type
+		for _, k := range exp.Keys {
+			kt := c.expr(k)
+			if !hashable(kt) {
+				c.errorf(diag.UnhashableKey, exp.Token, "unusable as hash key: %s", kt)
+			}
+			key = Join(key, kt)
+			value = Join(value, c.expr(exp.Pairs[k]))
+		}
+		if key == nil {
+			key, value = Dynamic, Dynamic
+		}
+		return &Hash{Key: key, Value: value}
+	case *ast.IndexExpression:
+		return c.index(exp)
+	case *ast.SliceExpression:
+		return c.slice(exp)
+	case *ast.FunctionLiteral:
+		return c.function(exp)
+	case *ast.CallExpression:
+		return c.call(exp)
+	case *ast.MemberExpression:
+		return c.member(exp)
+	case *ast.MatchExpression:
+		return c.match(exp)
+	case *ast.AssignExpression:
+		if member, ok := exp.Target.(*ast.MemberExpression); ok {
+			c.expr(member)
+		}
+		return c.expr(exp.Value)
+	case *ast.YieldExpression:
+		c.expr(exp.Value)
+		return Null
+	case *ast.SelectExpression:
+		return c.selectCases(exp)
+	}
+	return Dynamic
+}
+
+// type of a match expression, joining the types of its arms. without an
+// arm matching everything it can also be null
+func (c *checker) match(exp *ast.MatchExpression) Type {
+	subject := c.expr(exp.Subject)
+
+	var typ Type
+	exhaustive := false
+	for _, arm := range exp.Arms {
+		outer := c.scope
+		c.scope = &scope{outer: outer, vars: map[string]variable{}, fn: outer.fn}
+
+		c.pattern(arm.Pattern, subject, false)
+		if arm.Guard != nil {
+			c.expr(arm.Guard)
+		}
+		typ = Join(typ, c.expr(arm.Body))
+
+		c.finish()
+		c.scope = outer
+
+		switch arm.Pattern.(type) {
+		case *ast.WildcardPattern, *ast.BindingPattern:
+			exhaustive = exhaustive || arm.Guard == nil
+		}
+	}
+
+	if !exhaustive {
+		typ = Join(typ, Null)
+	}
+	return typ
+}
+
+// bind the names of a pattern matched against a value of type typ. a
+// strict pattern has to match, like the ones of lets and parameters, so
+// values of another shape are reported
+func (c *checker) pattern(pattern ast.Pattern, typ Type, strict bool) {
+	switch pattern := pattern.(type) {
+	case *ast.BindingPattern:
+		c.define(pattern.Name.Value, typ)
+	case *ast.LiteralPattern:
+		c.expr(pattern.Value)
+	case *ast.ArrayPattern:
+		var elem Type = Dynamic
+		switch typ := typ.(type) {
+		case *Array:
+			elem = typ.Elem
+		default:
+			if strict && typ != Dynamic {
+				c.errorf(diag.PatternMismatch, pattern.Token, "cannot destructure %s as array", typ)
+			}
+		}
+		for _, el := range pattern.Elements {
+			c.pattern(el, elem, strict)
+		}
+		if pattern.Rest != nil && pattern.Rest.Value != "_" {
+			c.define(pattern.Rest.Value, &Array{Elem: elem})
+		}
+	case *ast.HashPattern:
+		var value Type = Dynamic
+		switch typ := typ.(type) {
+		case *Hash:
+			value = typ.Value
+		case *Struct:
+			for _, key := range pattern.Keys {
+				if s, ok := key.(*ast.StringLiteral); ok && !typ.HasField(s.Value) {
+					c.errorf(diag.UnknownField, s.Token, "%s has no field %s", typ.Name, s.Value)
+				}
+			}
+		default:
+			if strict && typ != Dynamic {
+				c.errorf(diag.PatternMismatch, pattern.Token, "cannot destructure %s as hash", typ)
+			}
+		}
+		for i, key := range pattern.Keys {
+			c.expr(key)
+			c.pattern(pattern.Values[i], value, strict)
+		}
+	}
+}
+
+func (c *checker) member(exp *ast.MemberExpression) Type {
+	obj := c.expr(exp.Object)
+	name := exp.Property.Value
+
+	switch obj := obj.(type) {
+	case *Struct:
+		if !obj.HasField(name) {
+			c.errorf(diag.UnknownField, exp.Property.Token, "%s has no field %s", obj.Name, name)
+		}
+		return Dynamic
+	}
+	if obj != Dynamic {
+		c.errorf(diag.UnknownField, exp.Property.Token, "cannot access field %s of %s", name, obj)
+	}
+	return Dynamic
+}
+
+func hashable(t Type) bool {
+	return t == Dynamic || t == Int || t == Decimal || t == String || t == Bool
+}
+
+// integers and decimals, which mix in arithmetic
+func numeric(t Type) bool {
+	return t == Int || t == Decimal
+}
+
+func (c *checker) prefix(exp *ast.PrefixExpression) Type {
+	right := c.expr(exp.Right)
+	switch exp.Operator {
+	case "!":
+		return Bool
+	case "-":
+		if right == Decimal {
+			return Decimal
+		}
+		if right != Int && right != Dynamic {
+			c.errorf(diag.UnknownOperator, exp.Token, "unknown operator: -%s", right)
+		}
+		return Int
+	}
+	return Dynamic
+}
+
+func (c *checker) infix(exp *ast.InfixExpression) Type {
+	left, right := c.expr(exp.Left), c.expr(exp.Right)
+	op := exp.Operator
+
+	if op == "==" || op == "!=" {
+		return Bool
+	}
+	// a ?? b is b when a is null, and a otherwise
+	if op == "??" {
+		if left == Null {
+			return right
+		}
+		return Join(left, right)
+	}
+
+	// with a decimal on either side arithmetic works with decimals
+	number := Int
+	if left == Decimal || right == Decimal {
+		number = Decimal
+	}
+
+	var result Type = Dynamic
+	switch op {
+	case "-", "*", "/":
+		result = number
+	case "<", ">":
+		result = Bool
+	}
+	if left == Dynamic || right == Dynamic {
+		return result
+	}
+
+	switch {
+	case numeric(left) && numeric(right):
+		if op == "+" {
+			return number
+		}
+		if result != Dynamic {
+			return result
+		}
+	case kind(left) != kind(right):
+		c.errorf(diag.TypeMismatch, exp.Token, "type mismatch: %s %s %s", left, op, right)
+		return result
+	case left == String && op == "+":
+		return String
+	}
+
+	c.errorf(diag.UnknownOperator, exp.Token, "unknown operator: %s %s %s", left, op, right)
+	return result
+}
+
+// kind of object a type's values are, like the evaluator sees them
+func kind(t Type) string {
+	switch t.(type) {
+	case *Array:
+		return "array"
+	case *Hash:
+		return "hash"
+	case *Func:
+		return "fn"
+	case *Struct:
+		return "struct"
+	}
+	return t.String()
+}
+
+func (c *checker) index(exp *ast.IndexExpression) Type {
+	left, index := c.expr(exp.Left), c.expr(exp.Index)
+	if exp.Optional && left == Null {
+		return Null
+	}
+
+	switch left := left.(type) {
+	case *Array:
+		c.expectIndex(index, exp.Token)
+		return left.Elem
+	case *Hash:
+		if !Assignable(index, left.Key) {
+			c.errorf(diag.IncompatibleTypes, exp.Token, "cannot use %s as %s key", index, left)
+		}
+		return left.Value
+	}
+
+	switch left {
+	case Dynamic:
+		return Dynamic
+	case String:
+		c.expectIndex(index, exp.Token)
+		return String
+	}
+	c.errorf(diag.IncompatibleTypes, exp.Token, "cannot index %s", left)
+	return Dynamic
+}
+
+func (c *checker) expectIndex(index Type, tok token.Token) {
+	if !Assignable(index, Int) {
+		c.errorf(diag.IncompatibleTypes, tok, "index must be int, got %s", index)
+	}
+}
+
+func (c *checker) slice(exp *ast.SliceExpression) Type {
+	left := c.expr(exp.Left)
+	for _, bound := range []ast.Expression{exp.Start, exp.End} {
+		if bound != nil {
+			c.expectIndex(c.expr(bound), exp.Token)
+		}
+	}
+
+	if _, ok := left.(*Array); ok || left == String || left == Dynamic {
+		return left
+	}
+	if exp.Optional && left == Null {
+		return Null
+	}
+	c.errorf(diag.IncompatibleTypes, exp.Token, "cannot slice %s", left)
+	return Dynamic
+}
+
+// type of a function from its annotations, its body is checked at the
+// end of the current scope
+func (c *checker) function(lit *ast.FunctionLiteral) Type {
+	fn := &Func{Optional: len(lit.Defaults), Result: Dynamic}
+	for _, param := range lit.Parameters {
+		fn.Params = append(fn.Params, c.paramType(lit, param))
+	}
+	if lit.Rest != nil {
+		fn.Rest = Dynamic
+		if ta, ok := lit.Types[lit.Rest]; ok {
+			switch typ := c.annotation(ta).(type) {
+			case *Array:
+				fn.Rest = typ.Elem
+			default:
+				if typ != Dynamic {
+					c.errorf(diag.IncompatibleTypes, ta.Token, "rest parameter %s must be an array, got %s", lit.Rest.Value, typ)
+				}
+			}
+		}
+	}
+	if lit.Generator {
+		fn.Result = Iterator
+	}
+	if lit.ReturnType != nil {
+		declared := c.annotation(lit.ReturnType)
+		if lit.Generator && !Assignable(Iterator, declared) {
+			c.errorf(diag.IncompatibleTypes, lit.ReturnType.Token, "generator returns iterator, not %s", declared)
+		} else {
+			fn.Result = declared
+		}
+	}
+
+	c.scope.pending = append(c.scope.pending, pendingBody{lit: lit, typ: fn})
+	return fn
+}
+
+func (c *checker) paramType(lit *ast.FunctionLiteral, param *ast.Identifier) Type {
+	if ta, ok := lit.Types[param]; ok {
+		return c.annotation(ta)
+	}
+	return Dynamic
+}
+
+func (c *checker) body(pending pendingBody) {
+	lit, fn := pending.lit, pending.typ
+
+	outer := c.scope
+	c.scope = &scope{outer: outer, vars: map[string]variable{}, fn: fn}
+	if lit.Generator {
+		// what the body returns only ends the generator, the call has
+		// already returned it
+		c.scope.fn = &Func{Params: fn.Params, Optional: fn.Optional, Rest: fn.Rest, Result: Dynamic}
+	}
+
+	for i, param := range lit.Parameters {
+		if def, ok := lit.Defaults[param]; ok {
+			if typ := c.expr(def); !Assignable(typ, fn.Params[i]) {
+				c.errorf(diag.IncompatibleTypes, param.Token, "cannot use %s as %s in default of %s", typ, fn.Params[i], param.Value)
+			}
+		}
+		if pattern, ok := lit.Patterns[param]; ok {
+			c.pattern(pattern, fn.Params[i], true)
+			continue
+		}
+		c.define(param.Value, fn.Params[i])
+	}
+	if lit.Rest != nil {
+		c.define(lit.Rest.Value, &Array{Elem: fn.Rest})
+	}
+
+	if lit.Body != nil {
+		typ := c.statements(lit.Body.Statements)
+		var last ast.Expression
+		if n := len(lit.Body.Statements); n > 0 {
+			if stmt, ok := lit.Body.Statements[n-1].(*ast.ExpressionStatement); ok {
+				last = stmt.Expression
+			}
+		}
+		if typ != nil && !c.assignable(last, typ, c.scope.fn.Result) {
+			tok := lit.Body.Token
+			if n := len(lit.Body.Statements); n > 0 {
+				tok = statementToken(lit.Body.Statements[n-1], tok)
+			}
+			c.errorf(diag.IncompatibleTypes, tok, "cannot return %s from function returning %s", typ, fn.Result)
+		}
+	}
+
+	c.finish()
+	c.scope = outer
+}
+
+func statementToken(stmt ast.Statement, fallback token.Token) token.Token {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token
+	case *ast.ReturnStatement:
+		return stmt.Token
+	case *ast.ExpressionStatement:
+		return stmt.Token
+	case *ast.ForStatement:
+		return stmt.Token
+	}
+	return fallback
+}
+
+func (c *checker) call(exp *ast.CallExpression) Type {
+	if member, ok := exp.Function.(*ast.MemberExpression); ok {
+		if recv := c.expr(member.Object); !isStruct(recv) {
+			return c.methodCall(exp, member, recv)
+		}
+	}
+
+	callee := c.expr(exp.Function)
+	args := make([]Type, len(exp.Arguments))
+	for i, arg := range exp.Arguments {
+		args[i] = c.expr(arg)
+	}
+	if exp.Optional && callee == Null {
+		return Null
+	}
+	return c.apply(exp, exp.Function.String(), callee, args)
+}
+
+func isStruct(t Type) bool {
+	_, ok := t.(*Struct)
+	return ok
+}
+
+// check recv.name(args) against the methods of the receiver's type, or
+// the builtin taking the receiver first
+func (c *checker) methodCall(exp *ast.CallExpression, member *ast.MemberExpression, recv Type) Type {
+	args := []Type{}
+	for _, arg := range exp.Arguments {
+		args = append(args, c.expr(arg))
+	}
+	c.result.Types[exp.Function] = Dynamic
+
+	name := member.Property.Value
+	if recv == Dynamic {
+		return Dynamic
+	}
+	if fn, ok := methods[kind(recv)][name]; ok {
+		return c.apply(exp, name, fn, args)
+	}
+	if fn, ok := builtins[name]; ok {
+		return c.apply(exp, name, fn, append([]Type{recv}, args...))
+	}
+	c.errorf(diag.UnknownMethod, member.Property.Token, "unknown method %s for %s", name, recv)
+	return Dynamic
+}
+
+// check a call of the function name of type callee with arguments of
+// types args
+func (c *checker) apply(exp *ast.CallExpression, name string, callee Type, args []Type) Type {
+	if callee == Dynamic {
+		return Dynamic
+	}
+	fn, ok := callee.(*Func)
+	if !ok {
+		c.errorf(diag.NotCallable, exp.Token, "not a function: %s", callee)
+		return Dynamic
+	}
+
+	required := len(fn.Params) - fn.Optional
+	if len(args) < required || (fn.Rest == nil && len(args) > len(fn.Params)) {
+		c.errorf(diag.WrongArgumentCount, exp.Token, "wrong number of arguments to %s: want=%s, got=%d",
+			name, arity(fn), len(args))
+		return fn.Result
+	}
+
+	for i, arg := range args {
+		param := fn.Rest
+		if i < len(fn.Params) {
+			param = fn.Params[i]
+		}
+		if !Assignable(arg, param) {
+			c.errorf(diag.IncompatibleTypes, exp.Token, "cannot use %s as %s in argument %d to %s", arg, param, i+1, name)
+		}
+	}
+	return fn.Result
+}
+
+func arity(fn *Func) string {
+	required := len(fn.Params) - fn.Optional
+	switch {
+	case fn.Rest != nil:
+		return strconv.Itoa(required) + " or more"
+	case fn.Optional > 0:
+		return strconv.Itoa(required) + ".." + strconv.Itoa(len(fn.Params))
+	}
+	return strconv.Itoa(required)
+}
+
+// type an annotation stands for, unknown names are reported and dynamic
+func (c *checker) annotation(ta *ast.TypeAnnotation) Type {
+	params := make([]Type, len(ta.Params))
+	for i, p := range ta.Params {
+		params[i] = c.annotation(p)
+	}
+
+	switch ta.Name {
+	case "int", "decimal", "bool", "string", "null", "range", "iterator", "task", "channel", "any":
+		if c.typeArgs(ta, 0) {
+			return map[string]Type{
+				"int": Int, "decimal": Decimal, "bool": Bool, "string": String, "null": Null, "range": Range,
+				"iterator": Iterator, "task": Task, "channel": Channel, "any": Dynamic,
+			}[ta.Name]
+		}
+	case "array":
+		if len(params) == 0 {
+			return &Array{Elem: Dynamic}
+		}
+		if c.typeArgs(ta, 1) {
+			return &Array{Elem: params[0]}
+		}
+	case "hash":
+		if len(params) == 0 {
+			return &Hash{Key: Dynamic, Value: Dynamic}
+		}
+		if c.typeArgs(ta, 2) {
+			return &Hash{Key: params[0], Value: params[1]}
+		}
+	case "fn":
+		fn := &Func{Params: params, Result: Dynamic}
+		if ta.Result != nil {
+			fn.Result = c.annotation(ta.Result)
+		}
+		return fn
+	default:
+		st := c.structType(ta.Name)
+		if st == nil {
+			c.errorf(diag.UnknownType, ta.Token, "unknown type %s", ta.Name)
+		} else if c.typeArgs(ta, 0) {
+			return st
+		}
+	}
+	return Dynamic
+}
+
+// struct declared with name, if its constructor is in scope
+func (c *checker) structType(name string) *Struct {
+	if fn, ok := c.lookup(name).(*Func); ok {
+		if st, ok := fn.Result.(*Struct); ok && st.Name == name {
+			return st
+		}
+	}
+	return nil
+}
+
+func (c *checker) typeArgs(ta *ast.TypeAnnotation, want int) bool {
+	if len(ta.Params) != want {
+		c.errorf(diag.UnknownType, ta.Token, "%s takes %d type arguments, got %d", ta.Name, want, len(ta.Params))
+		return false
+	}
+	return true
+}
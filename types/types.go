@@ -0,0 +1,225 @@
+// types/types.go
+
+package types
+
+import (
+	"intInGo/object"
+	"strings"
+)
+
+// static type of a value, mirroring the kinds of objects
+type Type interface {
+	String() string
+}
+
+// types without structure
+type Basic struct {
+	Name string
+}
+
+func (b *Basic) String() string { return b.Name }
+
+var (
+	// what unannotated code works with, checked only at runtime
+	Dynamic = &Basic{Name: "any"}
+
+	Int      = &Basic{Name: "int"}
+	Decimal  = &Basic{Name: "decimal"}
+	Bool     = &Basic{Name: "bool"}
+	String   = &Basic{Name: "string"}
+	Null     = &Basic{Name: "null"}
+	Range    = &Basic{Name: "range"}
+	Iterator = &Basic{Name: "iterator"}
+	Task     = &Basic{Name: "task"}
+	Channel  = &Basic{Name: "channel"}
+)
+
+type Array struct {
+	Elem Type
+}
+
+func (a *Array) String() string { return "array<" + a.Elem.String() + ">" }
+
+type Hash struct {
+	Key   Type
+	Value Type
+}
+
+func (h *Hash) String() string { return "hash<" + h.Key.String() + ", " + h.Value.String() + ">" }
+
+type Func struct {
+	Params   []Type
+	Optional int  // trailing parameters that have a default
+	Rest     Type // element type of the rest parameter, nil without one
+	Result   Type
+}
+
+func (f *Func) String() string {
+	params := []string{}
+	for i, p := range f.Params {
+		if i >= len(f.Params)-f.Optional {
+			params = append(params, p.String()+"?")
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
+	}
+	return "fn(" + strings.Join(params, ", ") + "): " + f.Result.String()
+}
+
+// values of a struct type, fields aren't annotated so they're dynamic
+type Struct struct {
+	Name   string
+	Fields []string
+}
+
+func (s *Struct) String() string { return s.Name }
+
+func (s *Struct) HasField(name string) bool {
+	for _, f := range s.Fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// type of the function constructing values of a struct
+func constructor(st *Struct) *Func {
+	fn := &Func{Params: make([]Type, len(st.Fields)), Result: st}
+	for i := range fn.Params {
+		fn.Params[i] = Dynamic
+	}
+	return fn
+}
+
+// whether both types are the same
+func Identical(a, b Type) bool {
+	switch a := a.(type) {
+	case *Basic:
+		return a == b
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && Identical(a.Elem, b.Elem)
+	case *Hash:
+		b, ok := b.(*Hash)
+		return ok && Identical(a.Key, b.Key) && Identical(a.Value, b.Value)
+	case *Func:
+		b, ok := b.(*Func)
+		if !ok || len(a.Params) != len(b.Params) || a.Optional != b.Optional || (a.Rest == nil) != (b.Rest == nil) {
+			return false
+		}
+		for i := range a.Params {
+			if !Identical(a.Params[i], b.Params[i]) {
+				return false
+			}
+		}
+		return (a.Rest == nil || Identical(a.Rest, b.Rest)) && Identical(a.Result, b.Result)
+	case *Struct:
+		b, ok := b.(*Struct)
+		if !ok || a.Name != b.Name || len(a.Fields) != len(b.Fields) {
+			return false
+		}
+		for i := range a.Fields {
+			if a.Fields[i] != b.Fields[i] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// whether a value of type value can be used where target is expected.
+// dynamic types fit everywhere and everything fits a dynamic type
+func Assignable(value, target Type) bool {
+	if value == Dynamic || target == Dynamic {
+		return true
+	}
+
+	switch target := target.(type) {
+	case *Array:
+		value, ok := value.(*Array)
+		return ok && Assignable(value.Elem, target.Elem)
+	case *Hash:
+		value, ok := value.(*Hash)
+		return ok && Assignable(value.Key, target.Key) && Assignable(value.Value, target.Value)
+	case *Func:
+		value, ok := value.(*Func)
+		if !ok || len(value.Params) != len(target.Params) {
+			return false
+		}
+		for i := range target.Params {
+			if !Assignable(target.Params[i], value.Params[i]) {
+				return false
+			}
+		}
+		return Assignable(value.Result, target.Result)
+	}
+	return Identical(value, target)
+}
+
+// type covering both a and b, dynamic when they differ. nil stands for
+// code that never completes, like a block ending in return
+func Join(a, b Type) Type {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case Identical(a, b):
+		return a
+	}
+	return Dynamic
+}
+
+// type of a runtime value. functions are dynamic since their objects
+// don't keep annotations
+func Of(obj object.Object) Type {
+	switch obj := obj.(type) {
+	case *object.Integer, *object.BigInt:
+		return Int
+	case *object.Decimal:
+		return Decimal
+	case *object.Boolean:
+		return Bool
+	case *object.String:
+		return String
+	case *object.Null:
+		return Null
+	case *object.Range:
+		return Range
+	case object.Iterator:
+		return Iterator
+	case *object.Task:
+		return Task
+	case *object.Channel:
+		return Channel
+	case *object.Array:
+		var elem Type
+		for _, el := range obj.Elements {
+			elem = Join(elem, Of(el))
+		}
+		if elem == nil {
+			elem = Dynamic
+		}
+		return &Array{Elem: elem}
+	case *object.Hash:
+		var key, value Type
+		for _, pair := range obj.Pairs {
+			key = Join(key, Of(pair.Key))
+			value = Join(value, Of(pair.Value))
+		}
+		if key == nil {
+			key, value = Dynamic, Dynamic
+		}
+		return &Hash{Key: key, Value: value}
+	case *object.StructType:
+		return constructor(&Struct{Name: obj.Name, Fields: obj.Fields})
+	case *object.Struct:
+		return &Struct{Name: obj.Def.Name, Fields: obj.Def.Fields}
+	}
+	return Dynamic
+}
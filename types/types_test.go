@@ -0,0 +1,252 @@
+// types/types_test.go
+
+package types
+
+import (
+	"intInGo/ast"
+	"intInGo/evaluator"
+	"intInGo/lexer"
+	"intInGo/object"
+	"intInGo/parser"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func check(t *testing.T, input string, opts Options) (*ast.Program, *Result) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program, Check(program, opts)
+}
+
+func TestCheckErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`"a" - 1`, []string{"1:5: error[E0201]: type mismatch: string - int"}},
+		{`"a" - "b"`, []string{"1:5: error[E0202]: unknown operator: string - string"}},
+		{`-true`, []string{"1:1: error[E0202]: unknown operator: -bool"}},
+		{`1.5d + "a"`, []string{`1:6: error[E0201]: type mismatch: decimal + string`}},
+		{`let price: int = 1.5d * 2`, []string{`1:5: error[E0400]: cannot use decimal as int in let price`}},
+		{`for (x in 1.5d) { x }`, []string{`1:1: error[E0211]: cannot iterate over decimal`}},
+		{`let h = null; h?["k"]; h?[1:]; let f = null; f?(1)`, nil},
+		{`let h = null; h["k"]`, []string{`1:16: error[E0400]: cannot index null`}},
+		{`let n: int = null ?? 1; let s: string = null ?? 1`, []string{`1:29: error[E0400]: cannot use int as string in let s`}},
+		{`true + false`, []string{"1:6: error[E0202]: unknown operator: bool + bool"}},
+		{`"a" + "b"; 1 < 2; 1 == "a"; !5`, nil},
+		{`let x = 5; x + "a"`, []string{"1:14: error[E0201]: type mismatch: int + string"}},
+		{`let x: int = "a";`, []string{"1:5: error[E0400]: cannot use string as int in let x"}},
+		{`let x: array<int> = [1, 2]; let y: string = x[0];`, []string{"1:33: error[E0400]: cannot use int as string in let y"}},
+		{`let x: array<int> = [1, "a"];`, []string{"1:5: error[E0400]: cannot use array<any> as array<int> in let x"}},
+		{`let x: array<array<int>> = [[1], ["a", 2]];`, []string{"1:5: error[E0400]: cannot use array<any> as array<array<int>> in let x"}},
+		{`let h: hash<string, int> = {"a": 1, "b": "c"};`, []string{"1:5: error[E0400]: cannot use hash<string, any> as hash<string, int> in let h"}},
+		{`let f = fn(): array<string> { ["a", 1] };`, []string{"1:31: error[E0400]: cannot return array<any> from function returning array<string>"}},
+		{`let x: array<any> = [1, "a"]; let y: array<int> = []; let z: array<int> = [1, x[0]];`, nil},
+		{`let h: hash<string, int> = {"a": 1}; h[1]`, []string{"1:39: error[E0400]: cannot use int as hash<string, int> key"}},
+		{`[1, 2]["a"]`, []string{"1:7: error[E0400]: index must be int, got string"}},
+		{`5[0]`, []string{"1:2: error[E0400]: cannot index int"}},
+		{`{[1]: 2}`, []string{"1:1: error[E0205]: unusable as hash key: array<int>"}},
+		{`5(1)`, []string{"1:2: error[E0204]: not a function: int"}},
+		{`let x: foo = 1;`, []string{"1:8: error[E0401]: unknown type foo"}},
+		{`let x: array<int, int> = [];`, []string{"1:8: error[E0401]: array takes 1 type arguments, got 2"}},
+
+		// functions
+		{`let f = fn(a: int): int { a * 2 }; f(2) + 1`, nil},
+		{`let f = fn(a: int): int { a * 2 }; f("x")`, []string{`1:37: error[E0400]: cannot use string as int in argument 1 to f`}},
+		{`let f = fn(a: int): int { a * 2 }; f(1) + "x"`, []string{`1:41: error[E0201]: type mismatch: int + string`}},
+		{`let f = fn(a: int): int { a * 2 }; f()`, []string{`1:37: error[E0207]: wrong number of arguments to f: want=1, got=0`}},
+		{`let f = fn(a: string): int { a }`, []string{`1:30: error[E0400]: cannot return string from function returning int`}},
+		{`let f = fn(a): int { return "a"; }`, []string{`1:22: error[E0400]: cannot return string from function returning int`}},
+		{`let f = fn(): int { }`, []string{`1:19: error[E0400]: cannot return null from function returning int`}},
+		{`let f = fn(a): int { if (a) { return 1 } else { 2 } }`, nil},
+		{`let f = fn(a, b: int = "x") { a }`, []string{`1:15: error[E0400]: cannot use string as int in default of b`}},
+		{`let f = fn(...r: array<int>): int { r[0] }; f(1, 2, "c")`, []string{`1:46: error[E0400]: cannot use string as int in argument 3 to f`}},
+		{`let f = fn(...r: int) { r }`, []string{`1:18: error[E0400]: rest parameter r must be an array, got int`}},
+		{`let f = fn(g: fn(int): int) { g(1) }; f(fn(x: int): int { x })`, nil},
+		{`let f = fn(g: fn(int): int) { g(1) }; f(fn(x: string): int { 1 })`, []string{`1:40: error[E0400]: cannot use fn(string): int as fn(int): int in argument 1 to f`}},
+		{`len("abc") + 1; len(1, 2)`, []string{`1:20: error[E0207]: wrong number of arguments to len: want=1, got=2`}},
+		{`format("%d", 1) - 1`, []string{`1:17: error[E0201]: type mismatch: string - int`}},
+
+		// structs
+		{`struct P { x, y }; let p: P = P(1, 2); p.x`, nil},
+		{`struct P { x, y }; P(1, 2).z`, []string{`1:28: error[E0208]: P has no field z`}},
+		{`struct P { x, y }; let p = P(1, 2); p.z = 1`, []string{`1:39: error[E0208]: P has no field z`}},
+		{`struct P { x, y }; P(1)`, []string{`1:21: error[E0207]: wrong number of arguments to P: want=2, got=1`}},
+		{`struct P { x }; struct Q { x }; let p: P = Q(1);`, []string{`1:37: error[E0400]: cannot use Q as P in let p`}},
+		{`let a = 5; a.x`, []string{`1:14: error[E0208]: cannot access field x of int`}},
+
+		// methods
+		{`"a".upper().len() + 1; [1].push(2).len(); {"a": 1}.keys()`, nil},
+		{`"a".upper() - 1`, []string{`1:13: error[E0201]: type mismatch: string - int`}},
+		{`"a".split(1)`, []string{`1:10: error[E0400]: cannot use int as string in argument 1 to split`}},
+		{`[1].foo()`, []string{`1:5: error[E0209]: unknown method foo for array<int>`}},
+		{`let f = fn(x) { x.foo() }`, nil},
+		{`struct P { f }; P(fn(x) { x }).f(1)`, nil},
+
+		// match
+		{`let x: int = match (1) { 1 => 2, _ => 3 };`, nil},
+		{`let x: int = match (1) { 1 => "a", _ => "b" };`, []string{`1:5: error[E0400]: cannot use string as int in let x`}},
+		{`let x: string = match (1) { 1 => 2, n => n };`, []string{`1:5: error[E0400]: cannot use int as string in let x`}},
+		{`let xs: array<int> = [1]; match (xs) { [h, ...t] => h - "a", _ => 0 }`, []string{`1:55: error[E0201]: type mismatch: int - string`}},
+		{`match ({"a": "b"}) { {"a": v} => v - 1 }`, []string{`1:36: error[E0201]: type mismatch: string - int`}},
+
+		// destructuring
+		{`let [a, ...r]: array<int> = [1, 2]; a - "x"`, []string{`1:39: error[E0201]: type mismatch: int - string`}},
+		{`let [a, b] = 5;`, []string{`1:5: error[E0210]: cannot destructure int as array`}},
+		{`let {a} = [1];`, []string{`1:5: error[E0210]: cannot destructure array<int> as hash`}},
+		{`struct P { x, y }; let {x, z} = P(1, 2);`, []string{`1:28: error[E0208]: P has no field z`}},
+		{`let f = fn([a, b]: array<string>) { a - 1 }`, []string{`1:39: error[E0201]: type mismatch: string - int`}},
+		{`let [a, b] = f(); a - "x"`, nil},
+
+		// loops
+		{`for (x in [1, 2]) { x - "a" }`, []string{"1:23: error[E0201]: type mismatch: int - string"}},
+		{`for (k, v in {"a": true}) { k - v }`, []string{"1:31: error[E0201]: type mismatch: string - bool"}},
+		{`for (k in {"a": 1}) { k - 1 }`, []string{"1:25: error[E0201]: type mismatch: string - int"}},
+		{`for (i, c in "abc") { i + c }`, []string{"1:25: error[E0201]: type mismatch: int + string"}},
+		{`let r: range = range(1, 10, 2); for (i in r) { i - 1 }`, nil},
+		{`range("a")`, []string{"1:6: error[E0400]: cannot use string as int in argument 1 to range"}},
+		{`for (x in 5) {}`, []string{"1:1: error[E0211]: cannot iterate over int"}},
+		{`let s = 0; for (x in [1]) { let s = "a" }; s - 1`, nil},
+
+		// generators
+		{`let g = fn(): iterator { yield 1; return "done" }; g().next()["done"]`, nil},
+		{`let g = fn() { yield 1 }; g() + 1`, []string{"1:31: error[E0201]: type mismatch: iterator + int"}},
+		{`let g = fn(): int { yield 1 }`, []string{"1:15: error[E0400]: generator returns iterator, not int"}},
+		{`let g = fn() { yield 1 - "a" }`, []string{"1:24: error[E0201]: type mismatch: int - string"}},
+		{`for (i, x in iter([1])) { i - "a" }`, []string{"1:29: error[E0201]: type mismatch: int - string"}},
+		{`iter([1]).collect().len(); iter("a").foo()`, []string{"1:38: error[E0209]: unknown method foo for iterator"}},
+
+		// concurrency
+		{`let t: task = spawn(fn() { 1 }); t.await(); await(t)`, nil},
+		{`await(5)`, []string{"1:6: error[E0400]: cannot use int as task in argument 1 to await"}},
+		{`let ch = channel(1); ch.send(1); send(ch, 2); ch.recv(); close(ch)`, nil},
+		{`channel("a")`, []string{"1:8: error[E0400]: cannot use string as int in argument 1 to channel"}},
+		{`let c = channel(); let x: int = select { v = recv(c) => 1, _ => 2 };`, nil},
+		{`let c = channel(); let x: int = select { send(c, 1) => "a", _ => "b" };`, []string{"1:24: error[E0400]: cannot use string as int in let x"}},
+		{`select { recv(5) => 1 }`, []string{"1:10: error[E0400]: cannot recv on int, not a channel"}},
+		{`select { send(5, 1) => 1 }`, []string{"1:10: error[E0400]: cannot send on int, not a channel"}},
+
+		// unannotated code is dynamic
+		{`let f = fn(a, b) { a - b }; f("a", true)`, nil},
+		{`let f = fn(a) { a }; f(1) - "x"`, nil},
+		{`unknown - "x"`, nil},
+
+		// bindings changed by a branch or later on are dynamic
+		{`let x = 1; if (true) { let x = "a"; x - 1 }`, []string{"1:39: error[E0201]: type mismatch: string - int"}},
+		{`let x = 1; if (true) { let x = "a" }; x - 1`, nil},
+		{`let x = 1; if (true) { let x = 2 }; x - "a"`, []string{"1:39: error[E0201]: type mismatch: int - string"}},
+		{`let x = 1; let f = fn() { x - "a" }; let x = "b";`, nil},
+		{`let fib = fn(n: int): int { if (n < 2) { n } else { fib(n - 1) + fib("a") } }`, []string{`1:69: error[E0400]: cannot use string as int in argument 1 to fib`}},
+	}
+
+	for _, tt := range tests {
+		_, result := check(t, tt.input, Options{})
+
+		var got []string
+		for _, d := range result.Diagnostics {
+			got = append(got, d.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong diagnostics for %s.\nwant=%v\ngot= %v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestInferredTypes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`1 + 2`, "int"},
+		{`"a" + "b"`, "string"},
+		{`1 < 2`, "bool"},
+		{`[1, 2]`, "array<int>"},
+		{`[1, "a"]`, "array<any>"},
+		{`[]`, "array<any>"},
+		{`{"a": [true]}`, "hash<string, array<bool>>"},
+		{`[1, 2][1:]`, "array<int>"},
+		{`"abc"[0]`, "string"},
+		{`fn(a: int, b: bool = true, ...c: array<string>): int { a }`, "fn(int, bool?, ...string): int"},
+		{`fn(a) { a }`, "fn(any): any"},
+		{`if (true) { 1 } else { 2 }`, "int"},
+		{`if (true) { 1 }`, "any"},
+		{`let f = fn(): string { "a" }; f()`, "string"},
+		{`len([])`, "int"},
+		{`12.50d`, "decimal"},
+		{`1.5d * 2`, "decimal"},
+		{`2 + 1.5d`, "decimal"},
+		{`-1.5d`, "decimal"},
+		{`1.5d < 2`, "bool"},
+		{`{1.5d: 1}`, "hash<decimal, int>"},
+		{`let f = fn(d: decimal): decimal { d / 3 }; f(1d)`, "decimal"},
+		{`round(1.25d, 1)`, "decimal"},
+		{`int(1.5d)`, "int"},
+		{`null`, "null"},
+		{`null ?? "a"`, "string"},
+		{`let h = {"a": 1}; h["b"] ?? 0`, "int"},
+		{`1 ?? "a"`, "any"},
+		{`let h = null; h?["k"]`, "null"},
+		{`let f = null; f?()`, "null"},
+		{`is_null(1)`, "bool"},
+	}
+
+	for _, tt := range tests {
+		program, result := check(t, tt.input, Options{})
+		stmts := program.Statements
+		exp := stmts[len(stmts)-1].(*ast.ExpressionStatement).Expression
+
+		if got := result.Types[exp]; got == nil || got.String() != tt.expected {
+			t.Errorf("wrong type for %s. want=%s, got=%v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	globals := map[string]Type{
+		"n":   Of(&object.Integer{Value: 1}),
+		"big": Of(&object.BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 64)}),
+		"xs":  Of(&object.Array{Elements: []object.Object{&object.String{Value: "a"}}}),
+	}
+
+	_, result := check(t, `n + big + xs[0]`, Options{Globals: globals})
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0].Message != "type mismatch: int + string" {
+		t.Errorf("globals not used. got=%v", result.Diagnostics)
+	}
+}
+
+func TestAssignable(t *testing.T) {
+	tests := []struct {
+		value, target Type
+		expected      bool
+	}{
+		{Int, Int, true},
+		{Int, String, false},
+		{Dynamic, Int, true},
+		{Int, Dynamic, true},
+		{&Array{Elem: Int}, &Array{Elem: Dynamic}, true},
+		{&Array{Elem: Dynamic}, &Array{Elem: Int}, true},
+		{&Array{Elem: Int}, &Array{Elem: String}, false},
+		{&Hash{Key: String, Value: Int}, &Hash{Key: String, Value: Int}, true},
+		{&Func{Params: []Type{Int}, Result: Bool}, &Func{Params: []Type{Int}, Result: Bool}, true},
+		{&Func{Params: []Type{Int}, Result: Bool}, &Func{Params: []Type{Int, Int}, Result: Bool}, false},
+		{Null, Int, false},
+	}
+
+	for _, tt := range tests {
+		if got := Assignable(tt.value, tt.target); got != tt.expected {
+			t.Errorf("Assignable(%s, %s) wrong. want=%t, got=%t", tt.value, tt.target, tt.expected, got)
+		}
+	}
+}
+
+func TestBuiltinsKnown(t *testing.T) {
+	for _, name := range evaluator.BuiltinNames() {
+		if _, ok := builtins[name]; !ok {
+			t.Errorf("no signature for builtin %s", name)
+		}
+	}
+}